@@ -1,15 +1,30 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/maxnetyaga/software-architecture-lab3/painter"
 	"github.com/maxnetyaga/software-architecture-lab3/painter/lang"
 	"github.com/maxnetyaga/software-architecture-lab3/ui"
+	"github.com/maxnetyaga/software-architecture-lab3/ui/headless"
+)
+
+var (
+	historyDepth = flag.Int("history", painter.DefaultHistoryDepth, "number of posted batches that can be undone")
+	scenesDir    = flag.String("scenes", "scenes", "directory where scenes are saved")
+	assetsDir    = flag.String("assets", "assets", "directory where uploaded images are kept")
+	fps          = flag.Int("fps", painter.DefaultFPS, "frame rate of animations")
+	noWindow     = flag.Bool("headless", false, "render in memory without opening a window")
 )
 
 func main() {
+	flag.Parse()
+
 	var (
 		pv ui.Visualizer
 
@@ -21,15 +36,44 @@ func main() {
 	pv.Title = "Simple painter (Variant 5)"
 
 	pv.OnScreenReady = opLoop.Start
+	pv.OnResize = opLoop.Resize
 	opLoop.Receiver = &pv
+	opLoop.HistoryDepth = *historyDepth
+	opLoop.FPS = *fps
 
 	opLoop.Done = make(chan struct{})
 
+	scenes := &painter.SceneStore{Dir: *scenesDir}
+	parser.Scenes = scenes
+
+	assets := &painter.AssetStore{Dir: *assetsDir}
+	if err := assets.Load(); err != nil {
+		log.Printf("Failed to load assets: %s", err)
+	}
+	parser.Assets = assets
+	opLoop.Assets = assets
+
 	go func() {
 		http.Handle("/", lang.HttpHandler(&opLoop, &parser))
+		http.Handle("/scenes/{name}", lang.ScenesHandler(scenes))
+		http.Handle("/assets", lang.AssetsHandler(assets))
+		http.Handle("/assets/{name}", lang.AssetsHandler(assets))
+		http.Handle("/events", lang.EventsHandler(&opLoop))
+		http.Handle("/snapshot.png", lang.SnapshotHandler(&opLoop))
+		http.Handle("/export.svg", lang.ExportHandler(&opLoop))
+		http.Handle("/recording.gif", lang.RecordingHandler(&opLoop))
+		http.Handle("/stream.mjpg", lang.StreamHandler(&opLoop))
 		log.Fatal(http.ListenAndServe("localhost:17000", nil))
 	}()
 
-	pv.Main()
+	if *noWindow {
+		opLoop.Receiver = &headless.Receiver{}
+		opLoop.Start(headless.Screen{})
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+		<-interrupt
+	} else {
+		pv.Main()
+	}
 	opLoop.StopAndWait()
 }
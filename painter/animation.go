@@ -0,0 +1,165 @@
+package painter
+
+import (
+	"image/color"
+	"math"
+	"time"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// DefaultFPS is the frame rate of the Loop clock when Loop.FPS is not set.
+const DefaultFPS = 60
+
+type Easing string
+
+const (
+	EaseLinear Easing = "linear"
+	EaseIn     Easing = "ease-in"
+	EaseOut    Easing = "ease-out"
+	EaseInOut  Easing = "ease-in-out"
+)
+
+func ValidEasing(e Easing) bool {
+	switch e {
+	case "", EaseLinear, EaseIn, EaseOut, EaseInOut:
+		return true
+	}
+	return false
+}
+
+// apply maps linear time t in [0, 1] to eased progress in [0, 1]. The empty
+// easing is linear.
+func (e Easing) apply(t float64) float64 {
+	switch e {
+	case EaseIn:
+		return t * t
+	case EaseOut:
+		return 1 - (1-t)*(1-t)
+	case EaseInOut:
+		if t < 0.5 {
+			return 2 * t * t
+		}
+		return 1 - 2*(1-t)*(1-t)
+	default:
+		return t
+	}
+}
+
+// Tween is a change that can be applied gradually. Step applies the part of
+// the change between the progress values from and to, which grow from 0 to 1
+// over the animation.
+type Tween interface {
+	Step(s *State, from, to float64)
+}
+
+type TweenList []Tween
+
+func (tl TweenList) Step(s *State, from, to float64) {
+	for _, t := range tl {
+		t.Step(s, from, to)
+	}
+}
+
+// AnimateOp applies Tween over Duration instead of at once. It is run by the
+// Loop, which advances animations on every frame of its clock.
+type AnimateOp struct {
+	Tween    Tween
+	Duration time.Duration
+	Easing   Easing
+}
+
+func (op AnimateOp) Do(t screen.Texture, s *State) bool {
+	return false
+}
+
+// StopAnimationsOp cancels the running animations where they are.
+type StopAnimationsOp struct{}
+
+func (op StopAnimationsOp) Do(t screen.Texture, s *State) bool {
+	return false
+}
+
+type animation struct {
+	AnimateOp
+	start    time.Time
+	progress float64
+}
+
+// advance steps the animation to now and reports whether it has finished.
+func (a *animation) advance(s *State, now time.Time) bool {
+	t := 1.0
+	if a.Duration > 0 {
+		t = math.Min(1, float64(now.Sub(a.start))/float64(a.Duration))
+	}
+	progress := a.Easing.apply(t)
+	a.Tween.Step(s, a.progress, progress)
+	a.progress = progress
+	return t >= 1
+}
+
+// approach moves v towards target by the part of the remaining distance that
+// the progress from..to stands for, so absolute changes end exactly at their
+// target even when something else changes v meanwhile.
+func approach(v, target, from, to float64) float64 {
+	if from >= 1 {
+		return target
+	}
+	return v + (target-v)*(to-from)/(1-from)
+}
+
+func approachColor(c, target color.Color, from, to float64) color.Color {
+	if to >= 1 {
+		return target
+	}
+	a := color.NRGBAModel.Convert(c).(color.NRGBA)
+	b := color.NRGBAModel.Convert(target).(color.NRGBA)
+	channel := func(x, y uint8) uint8 {
+		return uint8(math.Round(approach(float64(x), float64(y), from, to)))
+	}
+	return color.NRGBA{R: channel(a.R, b.R), G: channel(a.G, b.G), B: channel(a.B, b.B), A: channel(a.A, b.A)}
+}
+
+func (op MoveOp) Step(s *State, from, to float64) {
+	MoveOp{ID: op.ID, X: op.X * (to - from), Y: op.Y * (to - from)}.Do(nil, s)
+}
+
+func (op RotateOp) Step(s *State, from, to float64) {
+	op.Degrees *= to - from
+	op.Do(nil, s)
+}
+
+func (op ScaleOp) Step(s *State, from, to float64) {
+	op.Factor = math.Pow(op.Factor, to-from)
+	op.Do(nil, s)
+}
+
+func (op MoveToOp) Step(s *State, from, to float64) {
+	if f := s.Figure(op.ID); f != nil {
+		f.Center.X = approach(f.Center.X, op.X, from, to)
+		f.Center.Y = approach(f.Center.Y, op.Y, from, to)
+	}
+}
+
+func (op SizeOp) Step(s *State, from, to float64) {
+	if f := s.Figure(op.ID); f != nil {
+		f.Size = approach(f.Size, op.Size, from, to)
+	}
+}
+
+func (op AlphaOp) Step(s *State, from, to float64) {
+	if f := s.Figure(op.ID); f != nil {
+		f.Alpha = approach(f.Alpha, op.Alpha, from, to)
+	}
+}
+
+func (op ColorOp) Step(s *State, from, to float64) {
+	if f := s.Figure(op.ID); f != nil {
+		f.Color = approachColor(f.Color, op.Color, from, to)
+	}
+}
+
+func (op FillOp) Step(s *State, from, to float64) {
+	s.BackgroundColor = approachColor(s.BackgroundColor, op.Color, from, to)
+	s.BackgroundGradient = nil
+}
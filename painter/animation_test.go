@@ -0,0 +1,69 @@
+package painter
+
+import (
+	"image/color"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEasing(t *testing.T) {
+	for _, e := range []Easing{"", EaseLinear, EaseIn, EaseOut, EaseInOut} {
+		if e.apply(0) != 0 || e.apply(1) != 1 {
+			t.Errorf("Easing %q does not start at 0 and end at 1", e)
+		}
+		prev := 0.0
+		for i := 1; i <= 10; i++ {
+			v := e.apply(float64(i) / 10)
+			if v < prev {
+				t.Errorf("Easing %q is not monotonic at %v", e, float64(i)/10)
+			}
+			prev = v
+		}
+	}
+	if v := EaseInOut.apply(0.5); v != 0.5 {
+		t.Errorf("Expected ease-in-out to be halfway at half time, got %v", v)
+	}
+	if EaseIn.apply(0.25) >= 0.25 || EaseOut.apply(0.25) <= 0.25 {
+		t.Errorf("Ease-in should start slower and ease-out faster than linear")
+	}
+}
+
+func TestAnimation_Advance(t *testing.T) {
+	s := DefaultState()
+	s.Figures = []Figure{NewFigure("a", Vec{X: 0.25, Y: 0.25})}
+	start := time.Now()
+	a := &animation{
+		AnimateOp: AnimateOp{
+			Tween: TweenList{
+				MoveOp{ID: "a", X: 0.5},
+				SizeOp{ID: "a", Size: 0.5},
+				ColorOp{ID: "a", Color: color.White},
+			},
+			Duration: time.Second,
+			Easing:   EaseInOut,
+		},
+		start: start,
+	}
+
+	if a.advance(s, start.Add(500*time.Millisecond)) {
+		t.Fatal("Animation finished halfway")
+	}
+	f := s.Figure("a")
+	if math.Abs(f.Center.X-0.5) > 1e-9 || math.Abs(f.Size-0.375) > 1e-9 {
+		t.Errorf("Expected the figure halfway to its target, got %v with size %v", f.Center, f.Size)
+	}
+
+	// Someone else resizes the figure meanwhile; absolute changes still end
+	// at their target.
+	f.Size = 0
+	if !a.advance(s, start.Add(2*time.Second)) {
+		t.Fatal("Animation did not finish after its duration")
+	}
+	if math.Abs(f.Center.X-0.75) > 1e-9 || f.Size != 0.5 {
+		t.Errorf("Unexpected figure after the animation: %v with size %v", f.Center, f.Size)
+	}
+	if f.Color != color.White {
+		t.Errorf("Expected the figure to end with the target color, got %v", f.Color)
+	}
+}
@@ -0,0 +1,128 @@
+package painter
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// BlendMode selects how a figure or rect is combined with what is already
+// drawn below it. The empty mode is the same as BlendOver.
+type BlendMode string
+
+const (
+	BlendOver     BlendMode = "over"
+	BlendMultiply BlendMode = "multiply"
+	BlendScreen   BlendMode = "screen"
+	BlendAdd      BlendMode = "add"
+	BlendXor      BlendMode = "xor"
+)
+
+func ValidBlendMode(m BlendMode) bool {
+	switch m {
+	case "", BlendOver, BlendMultiply, BlendScreen, BlendAdd, BlendXor:
+		return true
+	}
+	return false
+}
+
+// BlendOp sets the blend mode of the figure and the rect with the given ID.
+type BlendOp struct {
+	ID   string
+	Mode BlendMode
+}
+
+func (op BlendOp) Do(t screen.Texture, s *State) bool {
+	if f := s.Figure(op.ID); f != nil {
+		f.Blend = op.Mode
+	}
+	if i := s.bgRectIndex(op.ID); i >= 0 {
+		s.BgRects[i].Blend = op.Mode
+	}
+	return false
+}
+
+// premultiplied is a color with premultiplied alpha and channels in [0, 1].
+type premultiplied struct {
+	r, g, b, a float64
+}
+
+func premultiply(c color.Color) premultiplied {
+	r, g, b, a := c.RGBA()
+	return premultiplied{float64(r) / 0xffff, float64(g) / 0xffff, float64(b) / 0xffff, float64(a) / 0xffff}
+}
+
+func (c premultiplied) scale(k float64) premultiplied {
+	return premultiplied{c.r * k, c.g * k, c.b * k, c.a * k}
+}
+
+// blendPixel combines the source color with the RGBA pixel p in place. The
+// formulas are the separable modes of the W3C compositing spec written for
+// premultiplied colors; they apply to the alpha channel as well.
+func blendPixel(p []uint8, s premultiplied, mode BlendMode) {
+	d := premultiplied{float64(p[0]) / 0xff, float64(p[1]) / 0xff, float64(p[2]) / 0xff, float64(p[3]) / 0xff}
+	var f func(sc, dc float64) float64
+	switch mode {
+	case BlendMultiply:
+		f = func(sc, dc float64) float64 { return sc*(1-d.a) + dc*(1-s.a) + sc*dc }
+	case BlendScreen:
+		f = func(sc, dc float64) float64 { return sc + dc - sc*dc }
+	case BlendAdd:
+		f = func(sc, dc float64) float64 { return sc + dc }
+	case BlendXor:
+		f = func(sc, dc float64) float64 { return sc*(1-d.a) + dc*(1-s.a) }
+	default:
+		f = func(sc, dc float64) float64 { return sc + dc*(1-s.a) }
+	}
+	for i, v := range [4]float64{f(s.r, d.r), f(s.g, d.g), f(s.b, d.b), f(s.a, d.a)} {
+		p[i] = uint8(math.Round(math.Max(0, math.Min(1, v)) * 0xff))
+	}
+}
+
+// blendArea blends c into r of dst, scaled by the coverage in mask when it is
+// not nil. mask has to cover r.
+func blendArea(dst *image.RGBA, r image.Rectangle, mask *image.Alpha, c premultiplied, mode BlendMode) {
+	r = r.Intersect(dst.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			src := c
+			if mask != nil {
+				coverage := mask.AlphaAt(x, y).A
+				if coverage == 0 {
+					continue
+				}
+				src = c.scale(float64(coverage) / 0xff)
+			}
+			i := dst.PixOffset(x, y)
+			blendPixel(dst.Pix[i:i+4:i+4], src, mode)
+		}
+	}
+}
+
+// blendRect fills r of dst with a solid color.
+func blendRect(dst *image.RGBA, r image.Rectangle, c color.Color, mode BlendMode) {
+	if mode == "" || mode == BlendOver {
+		draw.Draw(dst, r, image.NewUniform(c), image.Point{}, draw.Over)
+		return
+	}
+	blendArea(dst, r, nil, premultiply(c), mode)
+}
+
+// blendPolygons is fillPolygons with a blend mode. The rasterizer can only
+// draw over, so for other modes the coverage is rasterized into a mask first.
+func blendPolygons(dst *image.RGBA, polygons [][]Vec, c color.Color, mode BlendMode) {
+	if mode == "" || mode == BlendOver {
+		fillPolygons(dst, polygons, image.NewUniform(c))
+		return
+	}
+	area := polygonBounds(polygons).Intersect(dst.Bounds())
+	if area.Empty() {
+		return
+	}
+	mask := image.NewAlpha(area)
+	fillPolygons(mask, polygons, image.Opaque)
+	blendArea(dst, area, mask, premultiply(c), mode)
+}
@@ -0,0 +1,153 @@
+package painter
+
+import (
+	"image"
+	"sync"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+const (
+	// CollideEdge stands for the edges of the canvas in collisions.
+	CollideEdge = "edge"
+	// CollideAny matches everything a figure can collide with in handlers.
+	CollideAny = "*"
+)
+
+// Collision reports that figure A has started touching B, which is another
+// figure, a background rect or CollideEdge.
+type Collision struct {
+	A string `json:"a"`
+	B string `json:"b"`
+}
+
+// contacts finds everything the visible figures touch, using their bounding
+// boxes on a canvas of the given size. Figures and rects on layers that are
+// not drawn are left out.
+func (s *State) contacts(canvas image.Point) []Collision {
+	type box struct {
+		id     string
+		lo, hi Vec
+	}
+	var figures []box
+	for _, f := range s.Figures {
+		if f.Hidden || !s.layerShown(f.Layer) {
+			continue
+		}
+		if lo, hi, ok := f.extent(canvas); ok {
+			figures = append(figures, box{f.ID, lo, hi})
+		}
+	}
+	overlap := func(a, b box) bool {
+		return a.lo.X <= b.hi.X && b.lo.X <= a.hi.X && a.lo.Y <= b.hi.Y && b.lo.Y <= a.hi.Y
+	}
+
+	var res []Collision
+	for i, a := range figures {
+		if a.lo.X <= 0 || a.lo.Y <= 0 || a.hi.X >= 1 || a.hi.Y >= 1 {
+			res = append(res, Collision{A: a.id, B: CollideEdge})
+		}
+		for _, r := range s.BgRects {
+			if s.layerShown(r.Layer) && overlap(a, box{r.ID, r.Min, r.Max}) {
+				res = append(res, Collision{A: a.id, B: r.ID})
+			}
+		}
+		for _, b := range figures[i+1:] {
+			if overlap(a, b) {
+				res = append(res, Collision{A: a.id, B: b.id})
+			}
+		}
+	}
+	return res
+}
+
+// OnCollideOp makes the Loop run Ops every time figure A starts touching B.
+// B is a figure, a background rect, CollideEdge or CollideAny. A handler for
+// the same pair is replaced.
+type OnCollideOp struct {
+	A, B string
+	Ops  OperationList
+}
+
+func (op OnCollideOp) Do(t screen.Texture, s *State) bool {
+	return false
+}
+
+// matches checks both orientations, because a collision between figures is
+// reported only once.
+func (op OnCollideOp) matches(c Collision) bool {
+	return op.A == c.A && (op.B == c.B || op.B == CollideAny) ||
+		op.A == c.B && (op.B == c.A || op.B == CollideAny)
+}
+
+// collisions keeps the contacts of the previous frame of a Loop, so handlers
+// and subscribers only hear about new ones.
+type collisions struct {
+	handlers []OnCollideOp
+	touching map[Collision]bool
+
+	mu          sync.Mutex
+	subscribers map[<-chan Collision]chan Collision
+}
+
+func (c *collisions) handle(op OnCollideOp) {
+	for i, h := range c.handlers {
+		if h.A == op.A && h.B == op.B {
+			c.handlers[i] = op
+			return
+		}
+	}
+	c.handlers = append(c.handlers, op)
+}
+
+func (c *collisions) watched() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.handlers) > 0 || len(c.subscribers) > 0
+}
+
+// detect returns the contacts that were not there on the previous call.
+func (c *collisions) detect(s *State, canvas image.Point) []Collision {
+	touching := map[Collision]bool{}
+	var started []Collision
+	for _, contact := range s.contacts(canvas) {
+		touching[contact] = true
+		if !c.touching[contact] {
+			started = append(started, contact)
+		}
+	}
+	c.touching = touching
+	return started
+}
+
+func (c *collisions) subscribe() <-chan Collision {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan Collision, 16)
+	if c.subscribers == nil {
+		c.subscribers = map[<-chan Collision]chan Collision{}
+	}
+	c.subscribers[ch] = ch
+	return ch
+}
+
+func (c *collisions) unsubscribe(ch <-chan Collision) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if sub, ok := c.subscribers[ch]; ok {
+		delete(c.subscribers, ch)
+		close(sub)
+	}
+}
+
+// publish never blocks the Loop: subscribers that do not keep up miss events.
+func (c *collisions) publish(event Collision) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, sub := range c.subscribers {
+		select {
+		case sub <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package painter
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestState_Contacts(t *testing.T) {
+	canvas := image.Pt(100, 100)
+	box := func(id string, x, y float64) Figure {
+		f := NewFigure(id, Vec{X: x, Y: y})
+		f.Shape, f.Size = "rectangle", 0.2
+		return f
+	}
+	s := DefaultState()
+	s.Figures = []Figure{box("a", 0.3, 0.3), box("b", 0.45, 0.3), box("c", 0.7, 0.7), box("d", 0.95, 0.5), box("hidden", 0.3, 0.3)}
+	s.Figures[4].Hidden = true
+	s.BgRects = []BgRect{{ID: "wall", Rect: Rect{Min: Vec{X: 0.75, Y: 0}, Max: Vec{X: 0.8, Y: 1}}}}
+
+	// Nothing on a hidden layer collides.
+	s.Layers = append(s.Layers, Layer{Name: "ghosts", Hidden: true, Opacity: 1})
+	ghost := box("ghost", 0.3, 0.3)
+	ghost.Layer = "ghosts"
+	s.Figures = append(s.Figures, ghost)
+	s.BgRects = append(s.BgRects, BgRect{ID: "ghostwall", Rect: Rect{Min: Vec{X: 0, Y: 0}, Max: Vec{X: 1, Y: 1}}, Layer: "ghosts"})
+
+	expected := []Collision{{A: "a", B: "b"}, {A: "c", B: "wall"}, {A: "d", B: CollideEdge}}
+	if got := s.contacts(canvas); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected contacts %v, got %v", expected, got)
+	}
+}
+
+func TestCollisions(t *testing.T) {
+	canvas := image.Pt(100, 100)
+	s := DefaultState()
+	s.Figures = []Figure{NewFigure("a", Vec{X: 0.5, Y: 0.5}), NewFigure("b", Vec{X: 0.55, Y: 0.5})}
+	var c collisions
+
+	if started := c.detect(s, canvas); !reflect.DeepEqual(started, []Collision{{A: "a", B: "b"}}) {
+		t.Errorf("Expected a new contact, got %v", started)
+	}
+	if started := c.detect(s, canvas); len(started) != 0 {
+		t.Errorf("Lasting contact was reported again: %v", started)
+	}
+	s.Figures[1].Center.X = 0.9
+	c.detect(s, canvas)
+	s.Figures[1].Center.X = 0.55
+	if started := c.detect(s, canvas); len(started) != 1 {
+		t.Errorf("Expected a contact to be reported again after it ended, got %v", started)
+	}
+
+	if !(OnCollideOp{A: "b", B: "a"}).matches(Collision{A: "a", B: "b"}) || !(OnCollideOp{A: "a", B: CollideAny}).matches(Collision{A: "a", B: CollideEdge}) {
+		t.Error("Handler does not match its collision")
+	}
+	if !(OnCollideOp{A: "b", B: CollideAny}).matches(Collision{A: "a", B: "b"}) {
+		t.Error("Wildcard handler does not match when its figure comes second")
+	}
+	if (OnCollideOp{A: "a", B: "c"}).matches(Collision{A: "a", B: "b"}) {
+		t.Error("Handler matches a different collision")
+	}
+
+	events := c.subscribe()
+	if !c.watched() {
+		t.Error("Subscribers are not watching collisions")
+	}
+	c.publish(Collision{A: "a", B: "b"})
+	if event := <-events; event != (Collision{A: "a", B: "b"}) {
+		t.Errorf("Unexpected event %v", event)
+	}
+	c.unsubscribe(events)
+	if _, ok := <-events; ok {
+		t.Error("Events channel is not closed after unsubscribing")
+	}
+	c.publish(Collision{A: "a", B: "b"})
+}
@@ -0,0 +1,87 @@
+package painter
+
+import (
+	"golang.org/x/exp/shiny/screen"
+)
+
+const DefaultHistoryDepth = 100
+
+// UndoOp restores the state from before the last N recorded batches. The
+// whole state is restored, so figures moved by physics or animations since
+// then jump back as well; running animations and timelines carry on from the
+// restored state.
+type UndoOp struct {
+	N int
+}
+
+func (op UndoOp) Do(t screen.Texture, s *State) bool {
+	return false
+}
+
+type RedoOp struct {
+	N int
+}
+
+func (op RedoOp) Do(t screen.Texture, s *State) bool {
+	return false
+}
+
+// history keeps snapshots of the state taken before every posted batch that
+// mutates it. Changes made by the frame clock are not recorded; see
+// Loop.tick. Only the latest depth snapshots are retained.
+type history struct {
+	depth int
+	undo  []*State
+	redo  []*State
+}
+
+func (h *history) push(s *State) {
+	depth := h.depth
+	if depth <= 0 {
+		depth = DefaultHistoryDepth
+	}
+	h.undo = append(h.undo, s)
+	if len(h.undo) > depth {
+		h.undo = h.undo[len(h.undo)-depth:]
+	}
+	h.redo = nil
+}
+
+func (h *history) back(current *State, n int) *State {
+	for ; n > 0 && len(h.undo) > 0; n-- {
+		h.redo = append(h.redo, current)
+		current = h.undo[len(h.undo)-1]
+		h.undo = h.undo[:len(h.undo)-1]
+	}
+	return current
+}
+
+func (h *history) forward(current *State, n int) *State {
+	for ; n > 0 && len(h.redo) > 0; n-- {
+		h.undo = append(h.undo, current)
+		current = h.redo[len(h.redo)-1]
+		h.redo = h.redo[:len(h.redo)-1]
+	}
+	return current
+}
+
+// mutates reports whether op may change the state. Batches that cannot are
+// neither snapshotted nor recorded, while the others are recorded even when
+// they happen to leave the state as it was.
+func mutates(op Operation) bool {
+	switch op := op.(type) {
+	case OperationList:
+		for _, o := range op {
+			if mutates(o) {
+				return true
+			}
+		}
+		return false
+	case updateOp, OperationFunc, UndoOp, RedoOp, SaveOp,
+		AnimateOp, StopAnimationsOp, TimelineOp, PlayOp, PauseOp,
+		OnCollideOp, RecordOp, StopRecordingOp:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,82 @@
+package painter
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	h := history{depth: 2}
+	states := make([]*State, 4)
+	for i := range states {
+		states[i] = DefaultState()
+		states[i].BackgroundColor = color.Gray{Y: uint8(i)}
+	}
+
+	for _, s := range states[:3] {
+		h.push(s)
+	}
+	if len(h.undo) != 2 {
+		t.Fatalf("Expected history to be bounded to 2 entries, got %d", len(h.undo))
+	}
+
+	current := h.back(states[3], 5)
+	if current != states[1] {
+		t.Errorf("Expected undo past the depth to stop at the oldest kept state, got %v", current.BackgroundColor)
+	}
+
+	current = h.forward(current, 1)
+	if current != states[2] {
+		t.Errorf("Expected redo to return the next state, got %v", current.BackgroundColor)
+	}
+
+	h.push(current)
+	if len(h.redo) != 0 {
+		t.Errorf("Expected a new change to clear the redo stack, got %d entries", len(h.redo))
+	}
+	if current = h.forward(current, 1); current != states[2] {
+		t.Errorf("Expected redo without entries to keep the current state")
+	}
+}
+
+func TestLoop_UndoSkipsUnchangedBatches(t *testing.T) {
+	l := Loop{State: DefaultState()}
+	l.execute(FigureOp{ID: "f1", X: 0.5, Y: 0.5})
+	l.execute(UpdateOp)
+	l.execute(UndoOp{N: 1})
+
+	if len(l.State.Figures) != 0 {
+		t.Errorf("Expected undo to remove the figure, got %d figures", len(l.State.Figures))
+	}
+}
+
+func TestMutates(t *testing.T) {
+	if mutates(OperationList{UpdateOp, UndoOp{N: 1}, PlayOp{Name: "intro"}, RecordOp{}}) {
+		t.Error("Expected a batch that only drives the Loop to leave the state alone")
+	}
+	if !mutates(OperationList{UpdateOp, OperationList{FigureOp{ID: "f1"}}}) {
+		t.Error("Expected a nested figure to be a change")
+	}
+}
+
+func TestLoop_ReadOnlyBatchesSkipSnapshots(t *testing.T) {
+	l := Loop{State: DefaultState()}
+	l.execute(FigureOp{ID: "f1", X: 0.5, Y: 0.5})
+	state := l.State
+	l.execute(OperationList{UpdateOp, PauseOp{Name: "intro"}, StopAnimationsOp{}})
+
+	if l.State != state || len(l.history.undo) != 1 {
+		t.Errorf("Expected a batch without changes to keep the state, got %d history entries", len(l.history.undo))
+	}
+}
+
+func TestLoop_InternalOpsSkipHistory(t *testing.T) {
+	l := Loop{State: DefaultState()}
+	l.execute(FigureOp{ID: "f1", X: 0.5, Y: 0.5})
+	// Even a call that touches the state is not recorded.
+	l.execute(callOp{fn: func() { l.State.BackgroundColor = color.White }, done: make(chan struct{})})
+
+	if len(l.history.undo) != 1 {
+		t.Errorf("Expected only the figure in history, got %d entries", len(l.history.undo))
+	}
+}
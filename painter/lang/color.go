@@ -0,0 +1,180 @@
+package lang
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/colornames"
+)
+
+func ParseColor(s string) (color.Color, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return nil, fmt.Errorf("empty color")
+	}
+
+	switch {
+	case strings.HasPrefix(s, "#"):
+		return parseHexColor(s[1:])
+	case strings.HasPrefix(s, "rgb"):
+		return parseRGBColor(s)
+	case strings.HasPrefix(s, "hsl"):
+		return parseHSLColor(s)
+	case s == "transparent":
+		return color.NRGBA{}, nil
+	}
+
+	if c, ok := colornames.Map[s]; ok {
+		return color.NRGBA{R: c.R, G: c.G, B: c.B, A: c.A}, nil
+	}
+	return nil, fmt.Errorf("unknown color: %s", s)
+}
+
+func parseHexColor(hex string) (color.Color, error) {
+	switch len(hex) {
+	case 3, 4:
+		var expanded strings.Builder
+		for _, r := range hex {
+			expanded.WriteRune(r)
+			expanded.WriteRune(r)
+		}
+		hex = expanded.String()
+	case 6, 8:
+	default:
+		return nil, fmt.Errorf("invalid hex color length: #%s", hex)
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex color: #%s", hex)
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+func colorFuncArgs(s string) (string, []string, error) {
+	open := strings.IndexByte(s, '(')
+	if open < 0 || !strings.HasSuffix(s, ")") {
+		return "", nil, fmt.Errorf("malformed color function: %s", s)
+	}
+	name := strings.TrimSpace(s[:open])
+	body := strings.ReplaceAll(s[open+1:len(s)-1], "/", ",")
+	var args []string
+	for _, part := range strings.Split(body, ",") {
+		args = append(args, strings.Fields(part)...)
+	}
+	return name, args, nil
+}
+
+func parseRGBColor(s string) (color.Color, error) {
+	name, args, err := colorFuncArgs(s)
+	if err != nil {
+		return nil, err
+	}
+	if (name != "rgb" && name != "rgba") || (len(args) != 3 && len(args) != 4) {
+		return nil, fmt.Errorf("expected rgb(r, g, b[, a]): %s", s)
+	}
+
+	var channels [3]uint8
+	for i := range channels {
+		v, err := parseChannel(args[i], 255)
+		if err != nil {
+			return nil, err
+		}
+		channels[i] = uint8(math.Round(v))
+	}
+	alpha, err := parseAlpha(args[3:])
+	if err != nil {
+		return nil, err
+	}
+	return color.NRGBA{R: channels[0], G: channels[1], B: channels[2], A: alpha}, nil
+}
+
+func parseHSLColor(s string) (color.Color, error) {
+	name, args, err := colorFuncArgs(s)
+	if err != nil {
+		return nil, err
+	}
+	if (name != "hsl" && name != "hsla") || (len(args) != 3 && len(args) != 4) {
+		return nil, fmt.Errorf("expected hsl(h, s%%, l%%[, a]): %s", s)
+	}
+
+	h, err := strconv.ParseFloat(strings.TrimSuffix(args[0], "deg"), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hue: %s", args[0])
+	}
+	sat, err := parseChannel(args[1], 1)
+	if err != nil {
+		return nil, err
+	}
+	light, err := parseChannel(args[2], 1)
+	if err != nil {
+		return nil, err
+	}
+	alpha, err := parseAlpha(args[3:])
+	if err != nil {
+		return nil, err
+	}
+
+	r, g, b := hslToRGB(h, sat, light)
+	return color.NRGBA{R: r, G: g, B: b, A: alpha}, nil
+}
+
+// parseChannel accepts either a plain number in [0, limit] or a percentage.
+func parseChannel(arg string, limit float64) (float64, error) {
+	percent := strings.HasSuffix(arg, "%")
+	v, err := strconv.ParseFloat(strings.TrimSuffix(arg, "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid color component: %s", arg)
+	}
+	if percent {
+		v = v / 100 * limit
+	}
+	return math.Max(0, math.Min(limit, v)), nil
+}
+
+func parseAlpha(args []string) (uint8, error) {
+	if len(args) == 0 {
+		return 0xff, nil
+	}
+	a, err := parseChannel(args[0], 1)
+	if err != nil {
+		return 0, err
+	}
+	return uint8(math.Round(a * 0xff)), nil
+}
+
+func hslToRGB(h, s, l float64) (uint8, uint8, uint8) {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+
+	c := (1 - math.Abs(2*l-1)) * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - c/2
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+
+	toByte := func(v float64) uint8 { return uint8(math.Round((v + m) * 0xff)) }
+	return toByte(r), toByte(g), toByte(b)
+}
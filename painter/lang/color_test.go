@@ -0,0 +1,45 @@
+package lang
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    color.Color
+		expectError bool
+	}{
+		{input: "#ff8000", expected: color.NRGBA{R: 0xff, G: 0x80, A: 0xff}},
+		{input: "#FF800080", expected: color.NRGBA{R: 0xff, G: 0x80, A: 0x80}},
+		{input: "#f80", expected: color.NRGBA{R: 0xff, G: 0x88, A: 0xff}},
+		{input: "#f808", expected: color.NRGBA{R: 0xff, G: 0x88, A: 0x88}},
+		{input: "rgb(10, 20, 30)", expected: color.NRGBA{R: 10, G: 20, B: 30, A: 0xff}},
+		{input: "rgba(100%,0%,0%,0.5)", expected: color.NRGBA{R: 0xff, A: 0x80}},
+		{input: "rgb(0 0 255 / 50%)", expected: color.NRGBA{B: 0xff, A: 0x80}},
+		{input: "hsl(120, 100%, 50%)", expected: color.NRGBA{G: 0xff, A: 0xff}},
+		{input: "hsla(240deg, 100%, 25%, 1)", expected: color.NRGBA{B: 0x80, A: 0xff}},
+		{input: "CornflowerBlue", expected: color.NRGBA{R: 0x64, G: 0x95, B: 0xed, A: 0xff}},
+		{input: "transparent", expected: color.NRGBA{}},
+		{input: "#12345", expectError: true},
+		{input: "#gggggg", expectError: true},
+		{input: "rgb(1, 2)", expectError: true},
+		{input: "hsl(a, 1%, 1%)", expectError: true},
+		{input: "rgb(1, 2, 3", expectError: true},
+		{input: "nosuchcolor", expectError: true},
+		{input: "", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseColor(tt.input)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("Expected error: %v, Got error: %v", tt.expectError, err)
+			}
+			if err == nil && got != tt.expected {
+				t.Errorf("Expected color %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -1,9 +1,17 @@
 package lang
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
 	"io"
+	"io/fs"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"fmt"
 
@@ -25,11 +33,357 @@ func HttpHandler(loop *painter.Loop, p *Parser) http.Handler {
 			return
 		}
 
-		for _, cmd := range cmds {
-			loop.Post(cmd)
+		var added painter.Added
+		if len(cmds) > 0 {
+			if added, err = loop.Execute(r.Context(), painter.OperationList(cmds)); err != nil {
+				log.Printf("Failed to run commands: %s", err)
+				switch {
+				case errors.Is(err, painter.ErrNotRunning) || r.Context().Err() != nil:
+					http.Error(rw, "Failed to run commands", http.StatusServiceUnavailable)
+				case errors.Is(err, fs.ErrNotExist):
+					http.Error(rw, "Failed to run commands: scene not found", http.StatusNotFound)
+				default:
+					http.Error(rw, "Failed to run commands", http.StatusInternalServerError)
+				}
+				return
+			}
 		}
 
 		rw.WriteHeader(http.StatusOK)
 		rw.Write([]byte("Commands received and posted to the event loop"))
+		if len(added.Figures) > 0 {
+			rw.Write([]byte(fmt.Sprintf("\nFigure IDs: %s", strings.Join(added.Figures, " "))))
+		}
+		if len(added.Rects) > 0 {
+			rw.Write([]byte(fmt.Sprintf("\nRect IDs: %s", strings.Join(added.Rects, " "))))
+		}
+		if len(added.Paths) > 0 {
+			rw.Write([]byte(fmt.Sprintf("\nPath IDs: %s", strings.Join(added.Paths, " "))))
+		}
+		if len(added.Texts) > 0 {
+			rw.Write([]byte(fmt.Sprintf("\nText IDs: %s", strings.Join(added.Texts, " "))))
+		}
+		if len(added.Sprites) > 0 {
+			rw.Write([]byte(fmt.Sprintf("\nSprite IDs: %s", strings.Join(added.Sprites, " "))))
+		}
+	})
+}
+
+// maxSceneSize limits the size of uploaded scenes.
+const maxSceneSize = 4 << 20
+
+// ScenesHandler serves GET and PUT requests for /scenes/{name}.
+func ScenesHandler(store *painter.SceneStore) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		if !painter.ValidSceneName(name) {
+			http.Error(rw, fmt.Sprintf("Invalid scene name: %q", name), http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			data, err := store.Read(name)
+			if errors.Is(err, fs.ErrNotExist) {
+				http.Error(rw, fmt.Sprintf("Scene %s not found", name), http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				log.Printf("Failed to read scene %s: %s", name, err)
+				http.Error(rw, "Failed to read scene", http.StatusInternalServerError)
+				return
+			}
+			rw.Header().Set("Content-Type", "application/json")
+			rw.Write(data)
+
+		case http.MethodPut:
+			data, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, maxSceneSize))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(rw, fmt.Sprintf("Scene is larger than %d bytes", maxSceneSize), http.StatusRequestEntityTooLarge)
+				return
+			}
+			if err != nil {
+				http.Error(rw, "Failed to read request body", http.StatusBadRequest)
+				return
+			}
+			if _, err := painter.UnmarshalScene(data); err != nil {
+				http.Error(rw, fmt.Sprintf("Invalid scene: %s", err), http.StatusBadRequest)
+				return
+			}
+			if err := store.Write(name, data); err != nil {
+				log.Printf("Failed to write scene %s: %s", name, err)
+				http.Error(rw, "Failed to store scene", http.StatusInternalServerError)
+				return
+			}
+			rw.WriteHeader(http.StatusNoContent)
+
+		default:
+			rw.Header().Set("Allow", "GET, PUT")
+			http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// maxAssetSize limits the size of uploaded images.
+const maxAssetSize = 10 << 20
+
+// AssetsHandler lists assets on GET /assets, and serves GET and PUT or POST
+// requests for /assets/{name}.
+func AssetsHandler(store *painter.AssetStore) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		if name == "" {
+			if r.Method != http.MethodGet {
+				rw.Header().Set("Allow", "GET")
+				http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			names, err := store.List()
+			if err != nil {
+				log.Printf("Failed to list assets: %s", err)
+				http.Error(rw, "Failed to list assets", http.StatusInternalServerError)
+				return
+			}
+			rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			for _, name := range names {
+				fmt.Fprintln(rw, name)
+			}
+			return
+		}
+		if !painter.ValidAssetName(name) {
+			http.Error(rw, fmt.Sprintf("Invalid asset name: %q", name), http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			data, err := store.Read(name)
+			if errors.Is(err, fs.ErrNotExist) {
+				http.Error(rw, fmt.Sprintf("Asset %s not found", name), http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				log.Printf("Failed to read asset %s: %s", name, err)
+				http.Error(rw, "Failed to read asset", http.StatusInternalServerError)
+				return
+			}
+			rw.Header().Set("Content-Type", http.DetectContentType(data))
+			rw.Write(data)
+
+		case http.MethodPut, http.MethodPost:
+			data, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, maxAssetSize))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(rw, fmt.Sprintf("Asset is larger than %d bytes", maxAssetSize), http.StatusRequestEntityTooLarge)
+				return
+			}
+			if err != nil {
+				http.Error(rw, "Failed to read request body", http.StatusBadRequest)
+				return
+			}
+			if _, err := painter.DecodeAsset(data); err != nil {
+				http.Error(rw, fmt.Sprintf("Invalid asset: %s", err), http.StatusBadRequest)
+				return
+			}
+			if err := store.Write(name, data); err != nil {
+				log.Printf("Failed to write asset %s: %s", name, err)
+				http.Error(rw, "Failed to store asset", http.StatusInternalServerError)
+				return
+			}
+			rw.WriteHeader(http.StatusNoContent)
+
+		default:
+			rw.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// EventsHandler streams the collisions detected by the loop as server-sent
+// events until the client goes away.
+func EventsHandler(loop *painter.Loop) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			rw.Header().Set("Allow", "GET")
+			http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		flusher, ok := rw.(http.Flusher)
+		if !ok {
+			http.Error(rw, "Streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+
+		events := loop.Subscribe()
+		defer loop.Unsubscribe(events)
+
+		rw.Header().Set("Content-Type", "text/event-stream")
+		rw.Header().Set("Cache-Control", "no-cache")
+		rw.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event := <-events:
+				data, err := json.Marshal(event)
+				if err != nil {
+					log.Printf("Failed to encode event: %s", err)
+					continue
+				}
+				fmt.Fprintf(rw, "event: collision\ndata: %s\n\n", data)
+				flusher.Flush()
+			}
+		}
+	})
+}
+
+// maxSnapshotSize limits the width and height of snapshots.
+const maxSnapshotSize = 4096
+
+// SnapshotHandler serves GET /snapshot.png, rendering the current state at
+// the size of the canvas or at the size given by the w and h query
+// parameters.
+func SnapshotHandler(loop *painter.Loop) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			rw.Header().Set("Allow", "GET")
+			http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var size image.Point
+		query := r.URL.Query()
+		if query.Has("w") || query.Has("h") {
+			w, errW := strconv.Atoi(query.Get("w"))
+			h, errH := strconv.Atoi(query.Get("h"))
+			if errW != nil || errH != nil || w <= 0 || h <= 0 || w > maxSnapshotSize || h > maxSnapshotSize {
+				http.Error(rw, fmt.Sprintf("Snapshot size must be given as w and h between 1 and %d", maxSnapshotSize), http.StatusBadRequest)
+				return
+			}
+			size = image.Pt(w, h)
+		}
+
+		img, err := loop.Snapshot(r.Context(), size)
+		if err != nil {
+			log.Printf("Failed to take a snapshot: %s", err)
+			http.Error(rw, "Failed to take a snapshot", http.StatusServiceUnavailable)
+			return
+		}
+		rw.Header().Set("Content-Type", "image/png")
+		if err := png.Encode(rw, img); err != nil {
+			log.Printf("Failed to encode snapshot: %s", err)
+		}
+	})
+}
+
+// ExportHandler serves GET /export.svg with the current state as SVG.
+func ExportHandler(loop *painter.Loop) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			rw.Header().Set("Allow", "GET")
+			http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		state, size, err := loop.Inspect(r.Context())
+		if err != nil {
+			log.Printf("Failed to read the state: %s", err)
+			http.Error(rw, "Failed to read the state", http.StatusServiceUnavailable)
+			return
+		}
+		rw.Header().Set("Content-Type", "image/svg+xml")
+		if err := painter.WriteSVG(rw, state, size, loop.Assets); err != nil {
+			log.Printf("Failed to export SVG: %s", err)
+		}
+	})
+}
+
+// RecordingHandler serves GET /recording.gif with the last recording, once it
+// has been encoded.
+func RecordingHandler(loop *painter.Loop) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			rw.Header().Set("Allow", "GET")
+			http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		data, err := loop.Recording(r.Context())
+		if errors.Is(err, painter.ErrNoRecording) {
+			http.Error(rw, "Nothing has been recorded yet", http.StatusNotFound)
+			return
+		}
+		if err != nil && r.Context().Err() != nil {
+			log.Printf("Failed to wait for the recording: %s", err)
+			http.Error(rw, "Failed to wait for the recording", http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to encode recording: %s", err)
+			http.Error(rw, "Failed to encode recording", http.StatusInternalServerError)
+			return
+		}
+		rw.Header().Set("Content-Type", "image/gif")
+		rw.Write(data)
+	})
+}
+
+// streamBoundary separates the frames of /stream.mjpg.
+const streamBoundary = "frame"
+
+// StreamHandler serves GET /stream.mjpg, a multipart JPEG stream that starts
+// with the current canvas and gets a frame every time the loop draws one.
+func StreamHandler(loop *painter.Loop) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			rw.Header().Set("Allow", "GET")
+			http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		flusher, ok := rw.(http.Flusher)
+		if !ok {
+			http.Error(rw, "Streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+
+		frames := loop.SubscribeFrames()
+		defer loop.UnsubscribeFrames(frames)
+		frame, err := loop.Snapshot(r.Context(), image.Point{})
+		if err != nil {
+			log.Printf("Failed to take a snapshot: %s", err)
+			http.Error(rw, "Failed to take a snapshot", http.StatusServiceUnavailable)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+streamBoundary)
+		rw.Header().Set("Cache-Control", "no-cache")
+		rw.WriteHeader(http.StatusOK)
+
+		var buf bytes.Buffer
+		for {
+			buf.Reset()
+			if err := jpeg.Encode(&buf, frame, nil); err != nil {
+				log.Printf("Failed to encode frame: %s", err)
+				return
+			}
+			fmt.Fprintf(rw, "--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n", streamBoundary, buf.Len())
+			rw.Write(buf.Bytes())
+			if _, err := io.WriteString(rw, "\r\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+
+			select {
+			case <-r.Context().Done():
+				return
+			case frame, ok = <-frames:
+				if !ok {
+					return
+				}
+			}
+		}
 	})
 }
@@ -0,0 +1,319 @@
+package lang
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/shiny/screen"
+
+	"github.com/maxnetyaga/software-architecture-lab3/painter"
+	"github.com/maxnetyaga/software-architecture-lab3/ui/headless"
+)
+
+// serve routes a request to handler through a mux registered for pattern, so
+// that path values are set like in the real server.
+func serve(pattern string, handler http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.Handle(pattern, handler)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return rec
+}
+
+func TestScenesHandler(t *testing.T) {
+	handler := ScenesHandler(&painter.SceneStore{Dir: t.TempDir()})
+	scene, err := painter.MarshalScene(painter.DefaultState())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := serve("/scenes/{name}", handler, http.MethodGet, "/scenes/demo", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing scene, got %d", rec.Code)
+	}
+	if rec := serve("/scenes/{name}", handler, http.MethodPut, "/scenes/demo", string(scene)); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204 for a stored scene, got %d: %s", rec.Code, rec.Body)
+	}
+	rec := serve("/scenes/{name}", handler, http.MethodGet, "/scenes/demo", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected a JSON scene, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if rec.Body.String() != string(scene) {
+		t.Errorf("Stored scene was changed: %s", rec.Body)
+	}
+
+	for name, body := range map[string]string{
+		"malformed json":  `{"version": 1`,
+		"unknown version": `{"version": 99, "background": "#000000ff", "figures": []}`,
+	} {
+		if rec := serve("/scenes/{name}", handler, http.MethodPut, "/scenes/broken", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, rec.Code)
+		}
+	}
+	if rec := serve("/scenes/{name}", handler, http.MethodPut, "/scenes/huge", strings.Repeat(" ", maxSceneSize+1)); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for a scene over the size limit, got %d", rec.Code)
+	}
+	if rec := serve("/scenes/{name}", handler, http.MethodGet, "/scenes/broken", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Rejected scene was stored: %d", rec.Code)
+	}
+
+	if rec := serve("/scenes/{name}", handler, http.MethodGet, "/scenes/..%2Fdemo", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a scene name with a path, got %d", rec.Code)
+	}
+	rec = serve("/scenes/{name}", handler, http.MethodDelete, "/scenes/demo", "")
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "GET, PUT" {
+		t.Errorf("Expected 405 with the allowed methods, got %d %q", rec.Code, rec.Header().Get("Allow"))
+	}
+}
+
+func TestAssetsHandler(t *testing.T) {
+	handler := AssetsHandler(&painter.AssetStore{Dir: t.TempDir()})
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewNRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := serve("/assets/{name}", handler, http.MethodPut, "/assets/http-test", img.String()); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204 for a stored asset, got %d: %s", rec.Code, rec.Body)
+	}
+	rec := serve("/assets/{name}", handler, http.MethodGet, "/assets/http-test", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" || rec.Body.String() != img.String() {
+		t.Errorf("Expected the stored PNG, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	rec = serve("/assets", handler, http.MethodGet, "/assets", "")
+	if rec.Code != http.StatusOK || rec.Body.String() != "http-test\n" {
+		t.Errorf("Expected the stored asset to be listed, got %d %q", rec.Code, rec.Body)
+	}
+	if rec := serve("/assets/{name}", handler, http.MethodGet, "/assets/missing", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing asset, got %d", rec.Code)
+	}
+
+	if rec := serve("/assets/{name}", handler, http.MethodPost, "/assets/text", "not an image"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a body that is not an image, got %d", rec.Code)
+	}
+	if rec := serve("/assets/{name}", handler, http.MethodPut, "/assets/huge", strings.Repeat("x", maxAssetSize+1)); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for an asset over the size limit, got %d", rec.Code)
+	}
+	for _, target := range []string{"/assets/..%2Fescape", "/assets/.hidden", "/assets/a%20b"} {
+		if rec := serve("/assets/{name}", handler, http.MethodPut, target, img.String()); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400 for an invalid name, got %d", target, rec.Code)
+		}
+	}
+	rec = serve("/assets", handler, http.MethodGet, "/assets", "")
+	if rec.Body.String() != "http-test\n" {
+		t.Errorf("Rejected uploads were stored: %q", rec.Body)
+	}
+
+	if rec := serve("/assets", handler, http.MethodPost, "/assets", img.String()); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for an upload without a name, got %d", rec.Code)
+	}
+}
+
+// startLoop runs a headless Loop until the end of the test.
+func startLoop(t *testing.T) *painter.Loop {
+	l := &painter.Loop{Receiver: &headless.Receiver{}}
+	l.Start(headless.Screen{})
+	t.Cleanup(l.StopAndWait)
+	return l
+}
+
+// blockOp holds the Loop up until release is closed.
+type blockOp struct {
+	release chan struct{}
+}
+
+func (op blockOp) Do(t screen.Texture, s *painter.State) bool {
+	<-op.release
+	return false
+}
+
+// block keeps the Loop from handling requests until the end of the test.
+func block(t *testing.T, l *painter.Loop) {
+	release := make(chan struct{})
+	l.Post(blockOp{release: release})
+	t.Cleanup(func() { close(release) })
+}
+
+// get serves a GET request for target, with a context that is already
+// cancelled if cancelled is set.
+func get(handler http.Handler, target string, cancelled bool) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	if cancelled {
+		ctx, cancel := context.WithCancel(r.Context())
+		cancel()
+		r = r.WithContext(ctx)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestHttpHandler(t *testing.T) {
+	l := startLoop(t)
+	handler := HttpHandler(l, &Parser{Scenes: &painter.SceneStore{Dir: t.TempDir()}})
+
+	rec := serve("/", handler, http.MethodPost, "/", "figure id=a 0.5 0.5\nsave demo")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Figure IDs: a") {
+		t.Errorf("Expected the figure to be added, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve("/", handler, http.MethodPost, "/", "reset\nload demo"); rec.Code != http.StatusOK {
+		t.Errorf("Expected the saved scene to load, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve("/", handler, http.MethodPost, "/", "load missing"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing scene, got %d", rec.Code)
+	}
+	if rec := serve("/", handler, http.MethodPost, "/", "figure id=a 0.5"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad script, got %d", rec.Code)
+	}
+}
+
+func TestSnapshotHandler(t *testing.T) {
+	l := startLoop(t)
+	handler := SnapshotHandler(l)
+
+	for target, size := range map[string]image.Point{
+		"/snapshot.png":            image.Pt(800, 800),
+		"/snapshot.png?w=40&h=30":  image.Pt(40, 30),
+		"/snapshot.png?w=4096&h=1": image.Pt(4096, 1),
+	} {
+		rec := get(handler, target, false)
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" {
+			t.Errorf("%s: expected a PNG, got %d %q", target, rec.Code, rec.Header().Get("Content-Type"))
+			continue
+		}
+		img, err := png.Decode(rec.Body)
+		if err != nil {
+			t.Errorf("%s: failed to decode the snapshot: %s", target, err)
+		} else if got := img.Bounds().Size(); got != size {
+			t.Errorf("%s: expected a %v snapshot, got %v", target, size, got)
+		}
+	}
+
+	for _, query := range []string{"w=40", "h=30", "w=0&h=30", "w=40&h=-1", "w=4097&h=30", "w=40&h=4097", "w=big&h=30", "w=4.5&h=30"} {
+		if rec := get(handler, "/snapshot.png?"+query, false); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+
+	if rec := get(SnapshotHandler(&painter.Loop{}), "/snapshot.png", false); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 from a loop that is not running, got %d", rec.Code)
+	}
+	block(t, l)
+	if rec := get(handler, "/snapshot.png", true); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for a cancelled request, got %d", rec.Code)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/snapshot.png", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", rec.Code)
+	}
+}
+
+func TestExportHandler(t *testing.T) {
+	l := startLoop(t)
+	handler := ExportHandler(l)
+	if _, err := l.Execute(context.Background(), painter.FigureOp{ID: "exported", X: 0.5, Y: 0.5}); err != nil {
+		t.Fatalf("Execute failed: %s", err)
+	}
+
+	rec := get(handler, "/export.svg", false)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/svg+xml" {
+		t.Errorf("Expected an SVG, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "<svg") || !strings.Contains(body, `id="exported"`) {
+		t.Errorf("Export does not contain the figure: %s", body)
+	}
+
+	if rec := get(ExportHandler(&painter.Loop{}), "/export.svg", false); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 from a loop that is not running, got %d", rec.Code)
+	}
+	block(t, l)
+	if rec := get(handler, "/export.svg", true); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for a cancelled request, got %d", rec.Code)
+	}
+}
+
+func TestRecordingHandler(t *testing.T) {
+	l := startLoop(t)
+	handler := RecordingHandler(l)
+
+	if rec := get(handler, "/recording.gif", false); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 before anything was recorded, got %d", rec.Code)
+	}
+	if rec := get(RecordingHandler(&painter.Loop{}), "/recording.gif", false); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 from a loop that is not running, got %d", rec.Code)
+	}
+
+	l.Post(painter.RecordOp{})
+	l.Post(painter.OperationList{painter.GreenOp{}, painter.UpdateOp})
+	l.Post(painter.StopRecordingOp{})
+	rec := get(handler, "/recording.gif", false)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/gif" {
+		t.Fatalf("Expected a GIF, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if anim, err := gif.DecodeAll(rec.Body); err != nil || len(anim.Image) == 0 {
+		t.Errorf("Expected a recording with frames, got %v", err)
+	}
+
+	block(t, l)
+	if rec := get(handler, "/recording.gif", true); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for a cancelled request, got %d", rec.Code)
+	}
+}
+
+// cancelOnFlush ends the request once the handler has flushed a response.
+type cancelOnFlush struct {
+	*httptest.ResponseRecorder
+	cancel context.CancelFunc
+}
+
+func (w cancelOnFlush) Flush() {
+	w.ResponseRecorder.Flush()
+	w.cancel()
+}
+
+func TestStreamHandler(t *testing.T) {
+	l := startLoop(t)
+	handler := StreamHandler(l)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rec := cancelOnFlush{ResponseRecorder: httptest.NewRecorder(), cancel: cancel}
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stream.mjpg", nil).WithContext(ctx))
+
+	mediaType, params, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if rec.Code != http.StatusOK || err != nil || mediaType != "multipart/x-mixed-replace" || params["boundary"] != "frame" {
+		t.Fatalf("Expected a multipart stream with the frame boundary, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.HasPrefix(rec.Body.String(), "--frame\r\n") {
+		t.Errorf("Stream does not start with a boundary: %q", rec.Body.String()[:min(rec.Body.Len(), 20)])
+	}
+	parts := multipart.NewReader(rec.Body, params["boundary"])
+	part, err := parts.NextPart()
+	if err != nil {
+		t.Fatalf("Failed to read the first frame: %s", err)
+	}
+	if part.Header.Get("Content-Type") != "image/jpeg" {
+		t.Errorf("Expected a JPEG frame, got %q", part.Header.Get("Content-Type"))
+	}
+	if img, err := jpeg.Decode(part); err != nil || img.Bounds().Size() != image.Pt(800, 800) {
+		t.Errorf("Expected a frame of the canvas size, got %v", err)
+	}
+
+	if rec := get(StreamHandler(&painter.Loop{}), "/stream.mjpg", false); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 from a loop that is not running, got %d", rec.Code)
+	}
+	block(t, l)
+	if rec := get(handler, "/stream.mjpg", true); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for a cancelled request, got %d", rec.Code)
+	}
+}
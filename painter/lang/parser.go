@@ -3,14 +3,24 @@ package lang
 import (
 	"bufio"
 	"fmt"
+	"image/color"
 	"io"
+	"math"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/maxnetyaga/software-architecture-lab3/painter"
 )
 
 type Parser struct {
+	// Scenes backs the save and load commands, which fail when it is nil.
+	Scenes *painter.SceneStore
+	// Assets holds the images that sprites may use; every asset is unknown
+	// when it is nil.
+	Assets *painter.AssetStore
 }
 
 func (p *Parser) Parse(in io.Reader) ([]painter.Operation, error) {
@@ -20,7 +30,18 @@ func (p *Parser) Parse(in io.Reader) ([]painter.Operation, error) {
 
 	for scanner.Scan() {
 		commandLine := scanner.Text()
-		op, err := parse(commandLine)
+		var (
+			op  painter.Operation
+			err error
+		)
+		if strings.HasSuffix(strings.TrimSpace(commandLine), "{") {
+			var body []string
+			if body, err = scanBlock(scanner); err == nil {
+				op, err = p.parseBlock(commandLine, body)
+			}
+		} else {
+			op, err = p.parse(commandLine)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse command '%s': %w", commandLine, err)
 		}
@@ -36,15 +57,75 @@ func (p *Parser) Parse(in io.Reader) ([]painter.Operation, error) {
 	return res, nil
 }
 
-func parse(commandLine string) (painter.Operation, error) {
-	fields := strings.Fields(commandLine)
+func (p *Parser) parse(commandLine string) (painter.Operation, error) {
+	fields, err := tokenize(commandLine)
+	if err != nil {
+		return nil, err
+	}
 	if len(fields) == 0 {
 		return nil, nil
 	}
 
-	instruction := fields[0]
-	args := fields[1:]
+	return p.parseCommand(fields[0], fields[1:])
+}
+
+// scanBlock reads the lines of a block up to its closing brace. Nested blocks
+// are kept in the body as they are.
+func scanBlock(scanner *bufio.Scanner) ([]string, error) {
+	var body []string
+	depth := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch trimmed := strings.TrimSpace(line); {
+		case trimmed == "}" && depth == 0:
+			return body, nil
+		case trimmed == "}":
+			depth--
+		case strings.HasSuffix(trimmed, "{"):
+			depth++
+		}
+		body = append(body, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("block is not closed with }")
+}
+
+func (p *Parser) parseBlock(header string, body []string) (painter.Operation, error) {
+	fields, err := tokenize(header)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 || fields[len(fields)-1] != "{" {
+		return nil, fmt.Errorf("expected { at the end of the block header")
+	}
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("block requires a command before {")
+	}
+	instruction, args := fields[0], fields[1:len(fields)-1]
 
+	switch instruction {
+	case "timeline":
+		return parseTimeline(args, body)
+	case "on":
+		if len(args) != 3 || args[0] != "collide" {
+			return nil, fmt.Errorf("on command requires collide and 2 ids")
+		}
+		if args[1] == painter.CollideEdge || args[1] == painter.CollideAny {
+			return nil, fmt.Errorf("the first id of on collide must be a figure")
+		}
+		ops, err := p.Parse(strings.NewReader(strings.Join(body, "\n")))
+		if err != nil {
+			return nil, err
+		}
+		return painter.OnCollideOp{A: args[1], B: args[2], Ops: ops}, nil
+	default:
+		return nil, fmt.Errorf("%s command does not take a block", instruction)
+	}
+}
+
+func (p *Parser) parseCommand(instruction string, args []string) (painter.Operation, error) {
 	switch instruction {
 	case "white":
 		if len(args) != 0 {
@@ -56,12 +137,34 @@ func parse(commandLine string) (painter.Operation, error) {
 			return nil, fmt.Errorf("unexpected arguments for green command")
 		}
 		return painter.GreenOp{}, nil
+	case "fill":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("fill command requires a color argument")
+		}
+		c, err := ParseColor(strings.Join(args, " "))
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument for fill: %w", err)
+		}
+		return painter.FillOp{Color: c}, nil
+	case "gradient":
+		g, err := parseGradient(args)
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument for gradient: %w", err)
+		}
+		return painter.GradientFillOp{Gradient: g}, nil
 	case "update":
 		if len(args) != 0 {
 			return nil, fmt.Errorf("unexpected arguments for update command")
 		}
 		return painter.UpdateOp, nil
 	case "bgrect":
+		args, opts, err := splitOptions(args, "id", "color")
+		if err != nil {
+			return nil, fmt.Errorf("invalid option for bgrect: %w", err)
+		}
+		if err := checkID(opts); err != nil {
+			return nil, fmt.Errorf("invalid option for bgrect: %w", err)
+		}
 		if len(args) != 4 {
 			return nil, fmt.Errorf("bgrect command requires 4 arguments")
 		}
@@ -81,8 +184,51 @@ func parse(commandLine string) (painter.Operation, error) {
 		if err != nil {
 			return nil, fmt.Errorf("invalid argument for bgrect: %w", err)
 		}
-		return painter.BgRectOp{X1: x1, Y1: y1, X2: x2, Y2: y2}, nil
+		var c color.Color
+		if raw, ok := opts["color"]; ok {
+			if c, err = ParseColor(raw); err != nil {
+				return nil, fmt.Errorf("invalid color for bgrect: %w", err)
+			}
+		}
+		return painter.BgRectOp{ID: opts["id"], X1: x1, Y1: y1, X2: x2, Y2: y2, Color: c}, nil
+	case "rectcolor":
+		if len(args) < 2 {
+			return nil, fmt.Errorf("rectcolor command requires a rect id and a color")
+		}
+		c, err := ParseColor(strings.Join(args[1:], " "))
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument for rectcolor: %w", err)
+		}
+		return painter.RectColorOp{ID: args[0], Color: c}, nil
+	case "rectgradient":
+		if len(args) < 1 {
+			return nil, fmt.Errorf("rectgradient command requires a rect id and a gradient")
+		}
+		g, err := parseGradient(args[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument for rectgradient: %w", err)
+		}
+		return painter.RectGradientOp{ID: args[0], Gradient: g}, nil
+	case "rectremove", "raise", "lower":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%s command requires a rect id", instruction)
+		}
+		switch instruction {
+		case "rectremove":
+			return painter.RectRemoveOp{ID: args[0]}, nil
+		case "raise":
+			return painter.RaiseOp{ID: args[0]}, nil
+		default:
+			return painter.LowerOp{ID: args[0]}, nil
+		}
 	case "figure":
+		args, opts, err := splitOptions(args, "id", "shape", "color", "size", "alpha")
+		if err != nil {
+			return nil, fmt.Errorf("invalid option for figure: %w", err)
+		}
+		if err := checkID(opts); err != nil {
+			return nil, fmt.Errorf("invalid option for figure: %w", err)
+		}
 		if len(args) != 2 {
 			return nil, fmt.Errorf("figure command requires 2 arguments")
 		}
@@ -94,10 +240,110 @@ func parse(commandLine string) (painter.Operation, error) {
 		if err != nil {
 			return nil, fmt.Errorf("invalid argument for figure: %w", err)
 		}
-		return painter.FigureOp{X: x, Y: y}, nil
+		if shape, ok := opts["shape"]; ok {
+			if _, err := painter.ParseShape(shape); err != nil {
+				return nil, fmt.Errorf("invalid shape for figure: %w", err)
+			}
+		}
+		style, err := parseStyle(opts)
+		if err != nil {
+			return nil, fmt.Errorf("invalid option for figure: %w", err)
+		}
+		var alpha *float64
+		if _, ok := opts["alpha"]; ok {
+			alpha = &style.alpha
+		}
+		return painter.FigureOp{
+			ID:    opts["id"],
+			X:     x,
+			Y:     y,
+			Shape: opts["shape"],
+			Color: style.color,
+			Size:  style.size,
+			Alpha: alpha,
+		}, nil
+	case "style":
+		args, opts, err := splitOptions(args, "color", "size", "alpha")
+		if err != nil {
+			return nil, fmt.Errorf("invalid option for style: %w", err)
+		}
+		if len(args) != 1 || len(opts) == 0 {
+			return nil, fmt.Errorf("style command requires a figure id and at least one of color, size or alpha")
+		}
+		style, err := parseStyle(opts)
+		if err != nil {
+			return nil, fmt.Errorf("invalid option for style: %w", err)
+		}
+		var ops painter.OperationList
+		if style.color != nil {
+			ops = append(ops, painter.ColorOp{ID: args[0], Color: style.color})
+		}
+		if _, ok := opts["size"]; ok {
+			ops = append(ops, painter.SizeOp{ID: args[0], Size: style.size})
+		}
+		if _, ok := opts["alpha"]; ok {
+			ops = append(ops, painter.AlphaOp{ID: args[0], Alpha: style.alpha})
+		}
+		return ops, nil
+	case "blend":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("blend command requires an id and a blend mode")
+		}
+		mode := painter.BlendMode(args[1])
+		if !painter.ValidBlendMode(mode) {
+			return nil, fmt.Errorf("invalid argument for blend: unknown blend mode %q", args[1])
+		}
+		return painter.BlendOp{ID: args[0], Mode: mode}, nil
+	case "line", "polyline", "quad", "cubic":
+		return p.parsePath(painter.PathKind(instruction), args)
+	case "pathremove":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("pathremove command requires a path id")
+		}
+		return painter.PathRemoveOp{ID: args[0]}, nil
+	case "text":
+		return p.parseText(args)
+	case "textremove":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("textremove command requires a text id")
+		}
+		return painter.TextRemoveOp{ID: args[0]}, nil
+	case "sprite":
+		args, opts, err := splitOptions(args, "id")
+		if err != nil {
+			return nil, fmt.Errorf("invalid option for sprite: %w", err)
+		}
+		if err := checkID(opts); err != nil {
+			return nil, fmt.Errorf("invalid option for sprite: %w", err)
+		}
+		if len(args) != 3 && len(args) != 5 {
+			return nil, fmt.Errorf("sprite command requires an asset, 2 coordinates and an optional width and height")
+		}
+		if _, ok := p.Assets.Lookup(args[0]); !ok {
+			return nil, fmt.Errorf("unknown asset: %s", args[0])
+		}
+		values := make([]float64, 4)
+		for i, arg := range args[1:] {
+			if values[i], err = strconv.ParseFloat(arg, 64); err != nil {
+				return nil, fmt.Errorf("invalid argument for sprite: %w", err)
+			}
+		}
+		if len(args) == 5 && (values[2] <= 0 || values[3] <= 0) {
+			return nil, fmt.Errorf("sprite width and height must be greater than 0")
+		}
+		return painter.SpriteOp{ID: opts["id"], Asset: args[0], X: values[0], Y: values[1], W: values[2], H: values[3]}, nil
+	case "spriteremove":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("spriteremove command requires a sprite id")
+		}
+		return painter.SpriteRemoveOp{ID: args[0]}, nil
 	case "move":
+		var id string
+		if len(args) == 3 {
+			id, args = args[0], args[1:]
+		}
 		if len(args) != 2 {
-			return nil, fmt.Errorf("move command requires 2 arguments")
+			return nil, fmt.Errorf("move command requires 2 arguments and an optional figure id")
 		}
 		x, err := strconv.ParseFloat(args[0], 64)
 		if err != nil {
@@ -107,7 +353,167 @@ func parse(commandLine string) (painter.Operation, error) {
 		if err != nil {
 			return nil, fmt.Errorf("invalid argument for move: %w", err)
 		}
-		return painter.MoveOp{X: x, Y: y}, nil
+		return painter.MoveOp{ID: id, X: x, Y: y}, nil
+	case "velocity", "acceleration":
+		var id string
+		if len(args) == 3 {
+			id, args = args[0], args[1:]
+		}
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%s command requires 2 arguments and an optional figure id", instruction)
+		}
+		x, err := strconv.ParseFloat(args[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument for %s: %w", instruction, err)
+		}
+		y, err := strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument for %s: %w", instruction, err)
+		}
+		if instruction == "velocity" {
+			return painter.VelocityOp{ID: id, X: x, Y: y}, nil
+		}
+		return painter.AccelerationOp{ID: id, X: x, Y: y}, nil
+	case "boundary":
+		var id string
+		if len(args) == 2 {
+			id, args = args[0], args[1:]
+		}
+		if len(args) != 1 {
+			return nil, fmt.Errorf("boundary command requires a boundary and an optional figure id")
+		}
+		boundary := painter.Boundary(args[0])
+		if boundary == "none" {
+			boundary = ""
+		}
+		if !painter.ValidBoundary(boundary) {
+			return nil, fmt.Errorf("invalid argument for boundary: unknown boundary %q", args[0])
+		}
+		return painter.BoundaryOp{ID: id, Boundary: boundary}, nil
+	case "rotate", "scale":
+		args, opts, err := splitOptions(args, "id")
+		if err != nil {
+			return nil, fmt.Errorf("invalid option for %s: %w", instruction, err)
+		}
+		if len(args) != 1 && len(args) != 3 {
+			return nil, fmt.Errorf("%s command requires a value and an optional pivot point", instruction)
+		}
+		values := make([]float64, len(args))
+		for i, arg := range args {
+			if values[i], err = strconv.ParseFloat(arg, 64); err != nil {
+				return nil, fmt.Errorf("invalid argument for %s: %w", instruction, err)
+			}
+		}
+		var pivot *painter.Vec
+		if len(values) == 3 {
+			pivot = &painter.Vec{X: values[1], Y: values[2]}
+		}
+		var ids []string
+		if raw, ok := opts["id"]; ok {
+			ids = strings.Split(raw, ",")
+			for _, id := range ids {
+				if !painter.ValidID(id) {
+					return nil, fmt.Errorf("invalid option for %s: invalid id %q", instruction, id)
+				}
+			}
+		}
+		if instruction == "rotate" {
+			return painter.RotateOp{IDs: ids, Degrees: values[0], Pivot: pivot}, nil
+		}
+		if values[0] <= 0 {
+			return nil, fmt.Errorf("scale factor must be greater than 0")
+		}
+		return painter.ScaleOp{IDs: ids, Factor: values[0], Pivot: pivot}, nil
+	case "moveto":
+		if len(args) != 3 {
+			return nil, fmt.Errorf("moveto command requires a figure id and 2 coordinates")
+		}
+		x, err := strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument for moveto: %w", err)
+		}
+		y, err := strconv.ParseFloat(args[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument for moveto: %w", err)
+		}
+		return painter.MoveToOp{ID: args[0], X: x, Y: y}, nil
+	case "remove":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("remove command requires a figure id")
+		}
+		return painter.RemoveOp{ID: args[0]}, nil
+	case "hide":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("hide command requires a figure id")
+		}
+		return painter.HideOp{ID: args[0]}, nil
+	case "show":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("show command requires a figure id")
+		}
+		return painter.ShowOp{ID: args[0]}, nil
+	case "layer":
+		return parseLayer(args)
+	case "animate":
+		return p.parseAnimate(args)
+	case "play", "pause":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%s command requires a timeline name", instruction)
+		}
+		if instruction == "play" {
+			return painter.PlayOp{Name: args[0]}, nil
+		}
+		return painter.PauseOp{Name: args[0]}, nil
+	case "seek":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("seek command requires a timeline name and a time")
+		}
+		at, err := time.ParseDuration(args[1])
+		if err != nil || at < 0 {
+			return nil, fmt.Errorf("invalid time for seek: %q", args[1])
+		}
+		return painter.SeekOp{Name: args[0], At: at}, nil
+	case "record":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("record command requires start or stop")
+		}
+		switch args[0] {
+		case "start":
+			return painter.RecordOp{}, nil
+		case "stop":
+			return painter.StopRecordingOp{}, nil
+		}
+		return nil, fmt.Errorf("invalid argument for record: %s", args[0])
+	case "undo", "redo":
+		if len(args) > 1 {
+			return nil, fmt.Errorf("%s command takes at most 1 argument", instruction)
+		}
+		n := 1
+		if len(args) == 1 {
+			var err error
+			n, err = strconv.Atoi(args[0])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid argument for %s: expected a positive step count", instruction)
+			}
+		}
+		if instruction == "undo" {
+			return painter.UndoOp{N: n}, nil
+		}
+		return painter.RedoOp{N: n}, nil
+	case "save", "load":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%s command requires a scene name", instruction)
+		}
+		if p.Scenes == nil {
+			return nil, fmt.Errorf("scene storage is not configured")
+		}
+		if !painter.ValidSceneName(args[0]) {
+			return nil, fmt.Errorf("invalid scene name: %s", args[0])
+		}
+		if instruction == "save" {
+			return painter.SaveOp{Store: p.Scenes, Name: args[0]}, nil
+		}
+		return painter.LoadOp{Store: p.Scenes, Name: args[0]}, nil
 	case "reset":
 		if len(args) != 0 {
 			return nil, fmt.Errorf("unexpected arguments for reset command")
@@ -116,4 +522,518 @@ func parse(commandLine string) (painter.Operation, error) {
 	default:
 		return nil, fmt.Errorf("unknown command: %s", instruction)
 	}
-}
\ No newline at end of file
+}
+
+// parsePath handles "<kind> [id=] [color=] [width=] [dash=a,b,...] [cap=]
+// [join=] x1 y1 x2 y2 ...".
+func (p *Parser) parsePath(kind painter.PathKind, args []string) (painter.Operation, error) {
+	args, opts, err := splitOptions(args, "id", "color", "width", "dash", "cap", "join")
+	if err != nil {
+		return nil, fmt.Errorf("invalid option for %s: %w", kind, err)
+	}
+	if err := checkID(opts); err != nil {
+		return nil, fmt.Errorf("invalid option for %s: %w", kind, err)
+	}
+	if len(args)%2 != 0 {
+		return nil, fmt.Errorf("%s command requires x y pairs", kind)
+	}
+	if err := painter.ValidatePath(kind, len(args)/2); err != nil {
+		return nil, err
+	}
+	points := make([]painter.Vec, len(args)/2)
+	for i := range points {
+		x, err := strconv.ParseFloat(args[2*i], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument for %s: %w", kind, err)
+		}
+		y, err := strconv.ParseFloat(args[2*i+1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument for %s: %w", kind, err)
+		}
+		points[i] = painter.Vec{X: x, Y: y}
+	}
+
+	stroke := painter.Stroke{Cap: painter.LineCap(opts["cap"]), Join: painter.LineJoin(opts["join"])}
+	if raw, ok := opts["color"]; ok {
+		if stroke.Color, err = ParseColor(raw); err != nil {
+			return nil, fmt.Errorf("invalid color for %s: %w", kind, err)
+		}
+	}
+	if raw, ok := opts["width"]; ok {
+		if stroke.Width, err = strconv.ParseFloat(raw, 64); err != nil || stroke.Width <= 0 {
+			return nil, fmt.Errorf("invalid width for %s: expected a positive number", kind)
+		}
+	}
+	if raw, ok := opts["dash"]; ok {
+		for _, d := range strings.Split(raw, ",") {
+			v, err := strconv.ParseFloat(d, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid dash for %s: %w", kind, err)
+			}
+			stroke.Dash = append(stroke.Dash, v)
+		}
+	}
+	if err := painter.ValidateStroke(stroke); err != nil {
+		return nil, fmt.Errorf("invalid stroke for %s: %w", kind, err)
+	}
+
+	return painter.PathOp{ID: opts["id"], Kind: kind, Points: points, Stroke: stroke}, nil
+}
+
+// parseText handles "text [id=] [size=] [color=] [align=] [figure=] x y
+// "content"". With figure= the coordinates are an offset from the figure.
+func (p *Parser) parseText(args []string) (painter.Operation, error) {
+	args, opts, err := splitOptions(args, "id", "size", "color", "align", "figure")
+	if err != nil {
+		return nil, fmt.Errorf("invalid option for text: %w", err)
+	}
+	if err := checkID(opts); err != nil {
+		return nil, fmt.Errorf("invalid option for text: %w", err)
+	}
+	if len(args) != 3 {
+		return nil, fmt.Errorf("text command requires 2 coordinates and a string")
+	}
+	x, err := strconv.ParseFloat(args[0], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid argument for text: %w", err)
+	}
+	y, err := strconv.ParseFloat(args[1], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid argument for text: %w", err)
+	}
+	content, err := unquote(args[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid string for text: %w", err)
+	}
+	style, err := parseStyle(opts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid option for text: %w", err)
+	}
+	if _, ok := opts["size"]; ok && !painter.ValidTextSize(style.size) {
+		return nil, fmt.Errorf("text size must be within (0, %g]", painter.MaxTextSize)
+	}
+	align := painter.TextAlign(opts["align"])
+	if !painter.ValidTextAlign(align) {
+		return nil, fmt.Errorf("unknown text alignment: %s", align)
+	}
+	return painter.TextOp{
+		ID:      opts["id"],
+		Content: content,
+		X:       x,
+		Y:       y,
+		Size:    style.size,
+		Color:   style.color,
+		Align:   align,
+		Figure:  opts["figure"],
+	}, nil
+}
+
+// parseAnimate handles "animate <command> [duration=d] [easing=e]", where the
+// command is a move, moveto, rotate, scale, style or fill command, and
+// "animate stop".
+func (p *Parser) parseAnimate(args []string) (painter.Operation, error) {
+	if len(args) == 1 && args[0] == "stop" {
+		return painter.StopAnimationsOp{}, nil
+	}
+	op := painter.AnimateOp{Duration: time.Second}
+	var command []string
+	for _, arg := range args {
+		key, value, _ := strings.Cut(arg, "=")
+		switch key {
+		case "duration":
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid duration for animate: %q", value)
+			}
+			op.Duration = d
+		case "easing":
+			op.Easing = painter.Easing(value)
+			if value == "" || !painter.ValidEasing(op.Easing) {
+				return nil, fmt.Errorf("unknown easing for animate: %q", value)
+			}
+		default:
+			command = append(command, arg)
+		}
+	}
+	if len(command) == 0 {
+		return nil, fmt.Errorf("animate command requires a command to animate")
+	}
+
+	inner, err := p.parseCommand(command[0], command[1:])
+	if err != nil {
+		return nil, err
+	}
+	switch inner := inner.(type) {
+	case painter.Tween:
+		op.Tween = inner
+	case painter.OperationList:
+		tweens := make(painter.TweenList, len(inner))
+		for i, o := range inner {
+			t, ok := o.(painter.Tween)
+			if !ok {
+				return nil, fmt.Errorf("%s command cannot be animated", command[0])
+			}
+			tweens[i] = t
+		}
+		op.Tween = tweens
+	default:
+		return nil, fmt.Errorf("%s command cannot be animated", command[0])
+	}
+	return op, nil
+}
+
+// parseTimeline handles "timeline <name> [mode=once|loop|pingpong] { ... }"
+// with one keyframe per line of the block:
+//
+//	<time> <figure> [center=x,y] [color=c] [size=s] [alpha=a] [easing=e]
+func parseTimeline(args []string, body []string) (painter.Operation, error) {
+	args, opts, err := splitOptions(args, "mode")
+	if err != nil {
+		return nil, fmt.Errorf("invalid option for timeline: %w", err)
+	}
+	if len(args) != 1 {
+		return nil, fmt.Errorf("timeline command requires a name")
+	}
+	tl := &painter.Timeline{Name: args[0], Mode: painter.TimelineMode(opts["mode"])}
+
+	for _, line := range body {
+		fields, err := tokenize(line)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		fields, opts, err := splitOptions(fields, "center", "color", "size", "alpha", "easing")
+		if err != nil {
+			return nil, fmt.Errorf("invalid option for keyframe: %w", err)
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("keyframe requires a time and a figure id: %q", line)
+		}
+		at, err := time.ParseDuration(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid keyframe time: %q", fields[0])
+		}
+		k := painter.Keyframe{At: at, Figure: fields[1], Easing: painter.Easing(opts["easing"])}
+		if raw, ok := opts["center"]; ok {
+			center, err := parsePoint(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid keyframe center: %w", err)
+			}
+			k.Center = &center
+		}
+		if raw, ok := opts["color"]; ok {
+			if k.Color, err = ParseColor(raw); err != nil {
+				return nil, fmt.Errorf("invalid keyframe color: %w", err)
+			}
+		}
+		if raw, ok := opts["size"]; ok {
+			size, err := strconv.ParseFloat(raw, 64)
+			if err != nil || size <= 0 {
+				return nil, fmt.Errorf("invalid keyframe size: %q", raw)
+			}
+			k.Size = &size
+		}
+		if raw, ok := opts["alpha"]; ok {
+			alpha, err := strconv.ParseFloat(raw, 64)
+			if err != nil || alpha < 0 || alpha > 1 {
+				return nil, fmt.Errorf("invalid keyframe alpha: %q", raw)
+			}
+			k.Alpha = &alpha
+		}
+		tl.Keyframes = append(tl.Keyframes, k)
+	}
+
+	if err := painter.ValidateTimeline(tl); err != nil {
+		return nil, err
+	}
+	return painter.TimelineOp{Timeline: tl}, nil
+}
+
+// parsePoint parses an "x,y" pair.
+func parsePoint(raw string) (painter.Vec, error) {
+	x, y, ok := strings.Cut(raw, ",")
+	if !ok {
+		return painter.Vec{}, fmt.Errorf("expected x,y")
+	}
+	var (
+		v   painter.Vec
+		err error
+	)
+	if v.X, err = strconv.ParseFloat(x, 64); err != nil {
+		return painter.Vec{}, err
+	}
+	if v.Y, err = strconv.ParseFloat(y, 64); err != nil {
+		return painter.Vec{}, err
+	}
+	return v, nil
+}
+
+// parseGradient handles "linear [angle=deg] stop stop ..." and
+// "radial [center=x,y] [radius=r] stop stop ...", where every stop is a color
+// with an optional "@offset", quoted when it contains spaces. Stops without
+// an offset are spread evenly between their neighbours.
+func parseGradient(args []string) (*painter.Gradient, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("expected linear or radial")
+	}
+	kind := painter.GradientKind(args[0])
+	args, opts, err := splitOptions(args[1:], "angle", "center", "radius")
+	if err != nil {
+		return nil, err
+	}
+
+	var g *painter.Gradient
+	switch kind {
+	case painter.GradientLinear:
+		if _, ok := opts["center"]; ok {
+			return nil, fmt.Errorf("linear gradients have no center")
+		}
+		if _, ok := opts["radius"]; ok {
+			return nil, fmt.Errorf("linear gradients have no radius")
+		}
+		g = painter.NewLinearGradient(0, nil)
+		if raw, ok := opts["angle"]; ok {
+			if g.Angle, err = strconv.ParseFloat(raw, 64); err != nil {
+				return nil, fmt.Errorf("invalid angle: %w", err)
+			}
+		}
+	case painter.GradientRadial:
+		if _, ok := opts["angle"]; ok {
+			return nil, fmt.Errorf("radial gradients have no angle")
+		}
+		g = painter.NewRadialGradient(painter.Vec{X: 0.5, Y: 0.5}, 0.5, nil)
+		if raw, ok := opts["center"]; ok {
+			if g.Center, err = parsePoint(raw); err != nil {
+				return nil, fmt.Errorf("invalid center: %w", err)
+			}
+		}
+		if raw, ok := opts["radius"]; ok {
+			if g.Radius, err = strconv.ParseFloat(raw, 64); err != nil {
+				return nil, fmt.Errorf("invalid radius: %w", err)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown gradient kind: %s", kind)
+	}
+
+	offsets := make([]float64, len(args))
+	for i, arg := range args {
+		arg, err := unquote(arg)
+		if err != nil {
+			return nil, err
+		}
+		raw, offset := arg, ""
+		if at := strings.LastIndexByte(arg, '@'); at >= 0 {
+			raw, offset = arg[:at], arg[at+1:]
+		}
+		c, err := ParseColor(raw)
+		if err != nil {
+			return nil, err
+		}
+		offsets[i] = math.NaN()
+		if offset != "" {
+			if offsets[i], err = strconv.ParseFloat(offset, 64); err != nil {
+				return nil, fmt.Errorf("invalid stop offset: %w", err)
+			}
+		}
+		g.Stops = append(g.Stops, painter.GradientStop{Color: c})
+	}
+	spreadOffsets(offsets)
+	for i := range g.Stops {
+		g.Stops[i].Offset = offsets[i]
+	}
+
+	if err := painter.ValidateGradient(g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// spreadOffsets replaces NaN offsets: the first and last default to 0 and 1,
+// and the ones in between are interpolated from their known neighbours.
+func spreadOffsets(offsets []float64) {
+	if len(offsets) == 0 {
+		return
+	}
+	if math.IsNaN(offsets[0]) {
+		offsets[0] = 0
+	}
+	if last := len(offsets) - 1; math.IsNaN(offsets[last]) {
+		offsets[last] = 1
+	}
+	known := 0
+	for i := 1; i < len(offsets); i++ {
+		if math.IsNaN(offsets[i]) {
+			continue
+		}
+		for j := known + 1; j < i; j++ {
+			offsets[j] = offsets[known] + (offsets[i]-offsets[known])*float64(j-known)/float64(i-known)
+		}
+		known = i
+	}
+}
+
+// parseLayer handles "layer new|use|show|hide|raise|lower <name>" and
+// "layer opacity <name> <a>".
+func parseLayer(args []string) (painter.Operation, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("layer command requires a subcommand and a layer name")
+	}
+	sub, name := args[0], args[1]
+	if sub != "opacity" && len(args) != 2 {
+		return nil, fmt.Errorf("unexpected arguments for layer %s", sub)
+	}
+	switch sub {
+	case "new":
+		return painter.LayerOp{Name: name}, nil
+	case "use":
+		return painter.UseLayerOp{Name: name}, nil
+	case "show":
+		return painter.LayerVisibilityOp{Name: name}, nil
+	case "hide":
+		return painter.LayerVisibilityOp{Name: name, Hidden: true}, nil
+	case "raise":
+		return painter.LayerRaiseOp{Name: name}, nil
+	case "lower":
+		return painter.LayerLowerOp{Name: name}, nil
+	case "opacity":
+		if len(args) != 3 {
+			return nil, fmt.Errorf("layer opacity requires a layer name and a value")
+		}
+		opacity, err := strconv.ParseFloat(args[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument for layer opacity: %w", err)
+		}
+		if opacity < 0 || opacity > 1 {
+			return nil, fmt.Errorf("layer opacity must be within [0, 1]")
+		}
+		return painter.LayerOpacityOp{Name: name, Opacity: opacity}, nil
+	default:
+		return nil, fmt.Errorf("unknown layer subcommand: %s", sub)
+	}
+}
+
+// splitOptions separates key=value options from positional arguments,
+// rejecting any key that is not in allowed.
+func splitOptions(args []string, allowed ...string) ([]string, map[string]string, error) {
+	var positional []string
+	opts := map[string]string{}
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || strings.HasPrefix(arg, `"`) {
+			positional = append(positional, arg)
+			continue
+		}
+		if !slices.Contains(allowed, key) {
+			return nil, nil, fmt.Errorf("unknown option %q", key)
+		}
+		if value == "" {
+			return nil, nil, fmt.Errorf("empty value for option %q", key)
+		}
+		unquoted, err := unquote(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid value for option %q: %w", key, err)
+		}
+		opts[key] = unquoted
+	}
+	return positional, opts, nil
+}
+
+// checkID rejects an id= option that cannot name an element.
+func checkID(opts map[string]string) error {
+	if id, ok := opts["id"]; ok && !painter.ValidID(id) {
+		return fmt.Errorf("invalid id %q: ids are made of letters, digits, _ and -, and %q is reserved", id, painter.CollideEdge)
+	}
+	return nil
+}
+
+// tokenize splits a command line on whitespace. Double-quoted strings, which
+// may contain spaces and Go escape sequences, stay within a single token
+// together with their quotes.
+func tokenize(line string) ([]string, error) {
+	var (
+		tokens   []string
+		token    strings.Builder
+		inToken  bool
+		inQuotes bool
+		escaped  bool
+	)
+	for _, r := range line {
+		switch {
+		case inQuotes:
+			token.WriteRune(r)
+			if escaped {
+				escaped = false
+			} else if r == '\\' {
+				escaped = true
+			} else if r == '"' {
+				inQuotes = false
+			}
+		case unicode.IsSpace(r):
+			if inToken {
+				tokens = append(tokens, token.String())
+				token.Reset()
+				inToken = false
+			}
+		default:
+			token.WriteRune(r)
+			inToken = true
+			inQuotes = r == '"'
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quoted string")
+	}
+	if inToken {
+		tokens = append(tokens, token.String())
+	}
+	return tokens, nil
+}
+
+// unquote strips the quotes from a quoted token and leaves other tokens as is.
+func unquote(token string) (string, error) {
+	if !strings.HasPrefix(token, `"`) {
+		return token, nil
+	}
+	return strconv.Unquote(token)
+}
+
+type figureStyle struct {
+	color color.Color
+	size  float64
+	alpha float64
+}
+
+func parseStyle(opts map[string]string) (figureStyle, error) {
+	var style figureStyle
+	if raw, ok := opts["color"]; ok {
+		c, err := ParseColor(raw)
+		if err != nil {
+			return style, err
+		}
+		style.color = c
+	}
+	if raw, ok := opts["size"]; ok {
+		size, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return style, fmt.Errorf("invalid size: %w", err)
+		}
+		if size <= 0 {
+			return style, fmt.Errorf("size must be greater than 0")
+		}
+		style.size = size
+	}
+	if raw, ok := opts["alpha"]; ok {
+		alpha, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return style, fmt.Errorf("invalid alpha: %w", err)
+		}
+		if alpha < 0 || alpha > 1 {
+			return style, fmt.Errorf("alpha must be within [0, 1]")
+		}
+		style.alpha = alpha
+	}
+	return style, nil
+}
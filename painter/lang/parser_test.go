@@ -1,16 +1,17 @@
 package lang
 
 import (
+	"image"
+	"image/color"
 	"strings"
 	"testing"
+	"time"
 	"reflect"
 
 	"github.com/maxnetyaga/software-architecture-lab3/painter"
 )
 
 func TestParser_Parse(t *testing.T) {
-	var p Parser
-
 	tests := []struct {
 		name string
 		input string
@@ -53,18 +54,333 @@ func TestParser_Parse(t *testing.T) {
 			expected: []painter.Operation{painter.BgRectOp{X1: 0.1, Y1: 0.2, X2: 0.8, Y2: 0.9}},
 			expectError: false,
 		},
+		{
+			name: "bgrect with id and color",
+			input: "bgrect id=hud color=#0000ff80 0 0 1 0.1\nbgrect 0 0 0.5 0.5",
+			expected: []painter.Operation{
+				painter.BgRectOp{ID: "hud", X1: 0, Y1: 0, X2: 1, Y2: 0.1, Color: color.NRGBA{B: 255, A: 128}},
+				painter.BgRectOp{X1: 0, Y1: 0, X2: 0.5, Y2: 0.5},
+			},
+			expectError: false,
+		},
+		{
+			name: "bgrect with invalid color",
+			input: "bgrect color=nope 0 0 1 1",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "valid rect commands",
+			input: "rectcolor hud rgb(1, 2, 3)\nrectremove a\nraise b\nlower c",
+			expected: []painter.Operation{
+				painter.RectColorOp{ID: "hud", Color: color.NRGBA{R: 1, G: 2, B: 3, A: 255}},
+				painter.RectRemoveOp{ID: "a"},
+				painter.RaiseOp{ID: "b"},
+				painter.LowerOp{ID: "c"},
+			},
+			expectError: false,
+		},
+		{
+			name: "rectcolor without color",
+			input: "rectcolor hud",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "raise without id",
+			input: "raise",
+			expected: nil,
+			expectError: true,
+		},
 		{
 			name: "valid figure command",
 			input: "figure 0.5 0.5",
 			expected: []painter.Operation{painter.FigureOp{X: 0.5, Y: 0.5}},
 			expectError: false,
 		},
+		{
+			name: "figure with explicit id",
+			input: "figure id=a 0.25 0.75",
+			expected: []painter.Operation{painter.FigureOp{ID: "a", X: 0.25, Y: 0.75}},
+			expectError: false,
+		},
+		{
+			name: "figures without an id are left to the state",
+			input: "figure 0.1 0.1\nfigure id=b 0.2 0.2\nfigure 0.3 0.3",
+			expected: []painter.Operation{
+				painter.FigureOp{X: 0.1, Y: 0.1},
+				painter.FigureOp{ID: "b", X: 0.2, Y: 0.2},
+				painter.FigureOp{X: 0.3, Y: 0.3},
+			},
+			expectError: false,
+		},
+		{
+			name: "figure with shape",
+			input: "figure id=s shape=star:6,0.4 0.5 0.5\nfigure shape=circle 0.1 0.1",
+			expected: []painter.Operation{
+				painter.FigureOp{ID: "s", X: 0.5, Y: 0.5, Shape: "star:6,0.4"},
+				painter.FigureOp{X: 0.1, Y: 0.1, Shape: "circle"},
+			},
+			expectError: false,
+		},
+		{
+			name: "figure with unknown shape",
+			input: "figure shape=blob 0.5 0.5",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "figure with invalid shape arguments",
+			input: "figure shape=ngon:2 0.5 0.5",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "figure with a huge side count",
+			input: "figure shape=ngon:1e300 0.5 0.5",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "figure with style options",
+			input: "figure color=#ff0000 size=0.1 alpha=0.5 0.5 0.5",
+			expected: []painter.Operation{painter.FigureOp{X: 0.5, Y: 0.5, Color: color.NRGBA{R: 255, A: 255}, Size: 0.1, Alpha: ptr(0.5)}},
+			expectError: false,
+		},
+		{
+			name: "figure with zero alpha",
+			input: "figure alpha=0 0.5 0.5",
+			expected: []painter.Operation{painter.FigureOp{X: 0.5, Y: 0.5, Alpha: ptr(0)}},
+			expectError: false,
+		},
+		{
+			name: "figure with a reserved id",
+			input: "figure id=edge 0.5 0.5",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "valid style command",
+			input: "style a color=blue size=0.2 alpha=0",
+			expected: []painter.Operation{painter.OperationList{
+				painter.ColorOp{ID: "a", Color: color.NRGBA{B: 255, A: 255}},
+				painter.SizeOp{ID: "a", Size: 0.2},
+				painter.AlphaOp{ID: "a", Alpha: 0},
+			}},
+			expectError: false,
+		},
+		{
+			name: "style without options",
+			input: "style a",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "style with alpha out of range",
+			input: "style a alpha=1.5",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "figure with unknown option",
+			input: "figure name=a 0.5 0.5",
+			expected: nil,
+			expectError: true,
+		},
 		{
 			name: "valid move command",
 			input: "move 0.01 0.02",
 			expected: []painter.Operation{painter.MoveOp{X: 0.01, Y: 0.02}},
 			expectError: false,
 		},
+		{
+			name: "valid move command for one figure",
+			input: "move a 0.01 0.02",
+			expected: []painter.Operation{painter.MoveOp{ID: "a", X: 0.01, Y: 0.02}},
+			expectError: false,
+		},
+		{
+			name: "valid rotate and scale commands",
+			input: "rotate 90\nrotate id=a,b -45 0.5 0.5\nscale 2\nscale id=a 0.5 0 0",
+			expected: []painter.Operation{
+				painter.RotateOp{Degrees: 90},
+				painter.RotateOp{IDs: []string{"a", "b"}, Degrees: -45, Pivot: &painter.Vec{X: 0.5, Y: 0.5}},
+				painter.ScaleOp{Factor: 2},
+				painter.ScaleOp{IDs: []string{"a"}, Factor: 0.5, Pivot: &painter.Vec{}},
+			},
+			expectError: false,
+		},
+		{
+			name: "scale with non-positive factor",
+			input: "scale 0",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "rotate with incomplete pivot",
+			input: "rotate 45 0.5",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "valid path commands",
+			input: "line 0 0 1 1\npolyline id=p color=red width=0.01 dash=0.02,0.01 cap=round join=bevel 0 0 0.5 0.5 1 0\nquad 0 0 0.5 1 1 0\ncubic 0 0 0.25 1 0.75 1 1 0\npathremove p",
+			expected: []painter.Operation{
+				painter.PathOp{Kind: painter.PathLine, Points: []painter.Vec{{X: 0, Y: 0}, {X: 1, Y: 1}}},
+				painter.PathOp{
+					ID:     "p",
+					Kind:   painter.PathPolyline,
+					Points: []painter.Vec{{X: 0, Y: 0}, {X: 0.5, Y: 0.5}, {X: 1, Y: 0}},
+					Stroke: painter.Stroke{
+						Width: 0.01,
+						Color: color.NRGBA{R: 0xff, A: 0xff},
+						Dash:  []float64{0.02, 0.01},
+						Cap:   painter.CapRound,
+						Join:  painter.JoinBevel,
+					},
+				},
+				painter.PathOp{Kind: painter.PathQuad, Points: []painter.Vec{{X: 0, Y: 0}, {X: 0.5, Y: 1}, {X: 1, Y: 0}}},
+				painter.PathOp{Kind: painter.PathCubic, Points: []painter.Vec{{X: 0, Y: 0}, {X: 0.25, Y: 1}, {X: 0.75, Y: 1}, {X: 1, Y: 0}}},
+				painter.PathRemoveOp{ID: "p"},
+			},
+			expectError: false,
+		},
+		{
+			name: "quad with missing control point",
+			input: "quad 0 0 1 1",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "line with a dash shorter than the stroke width",
+			input: "line 0 0 1 1 dash=0.0000001",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "line with too many dash entries",
+			input: "line dash=0.1,0.1,0.1,0.1,0.1,0.1,0.1,0.1,0.1,0.1,0.1,0.1,0.1,0.1,0.1,0.1,0.1 0 0 1 1",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "line with unknown cap",
+			input: "line cap=arrow 0 0 1 1",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "valid text commands",
+			input: "text 0.5 0.5 \"Hello, world\"\ntext id=score size=0.1 color=red align=right 1 0 \"Score: \\\"10\\\"\"\ntext figure=a 0 0.2 \"id=a\"\ntextremove score",
+			expected: []painter.Operation{
+				painter.TextOp{Content: "Hello, world", X: 0.5, Y: 0.5},
+				painter.TextOp{ID: "score", Content: `Score: "10"`, X: 1, Size: 0.1, Color: color.NRGBA{R: 0xff, A: 0xff}, Align: painter.AlignRight},
+				painter.TextOp{Content: "id=a", Y: 0.2, Figure: "a"},
+				painter.TextRemoveOp{ID: "score"},
+			},
+			expectError: false,
+		},
+		{
+			name: "quoted option value",
+			input: "text figure=\"a\" 0 0 \"label\"",
+			expected: []painter.Operation{painter.TextOp{Content: "label", Figure: "a"}},
+			expectError: false,
+		},
+		{
+			name: "text with unterminated string",
+			input: "text 0 0 \"Hello",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "text with unknown alignment",
+			input: "text align=justify 0 0 \"Hello\"",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "text larger than the size limit",
+			input: "text size=200 0 0 \"WWWW\"",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "valid moveto command",
+			input: "moveto a 0.3 0.4",
+			expected: []painter.Operation{painter.MoveToOp{ID: "a", X: 0.3, Y: 0.4}},
+			expectError: false,
+		},
+		{
+			name: "moveto without id",
+			input: "moveto 0.3 0.4",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "valid remove, hide and show commands",
+			input: "remove a\nhide b\nshow b",
+			expected: []painter.Operation{
+				painter.RemoveOp{ID: "a"},
+				painter.HideOp{ID: "b"},
+				painter.ShowOp{ID: "b"},
+			},
+			expectError: false,
+		},
+		{
+			name: "hide without id",
+			input: "hide",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "valid undo and redo commands",
+			input: "undo\nundo 3\nredo\nredo 2",
+			expected: []painter.Operation{
+				painter.UndoOp{N: 1},
+				painter.UndoOp{N: 3},
+				painter.RedoOp{N: 1},
+				painter.RedoOp{N: 2},
+			},
+			expectError: false,
+		},
+		{
+			name: "undo with non-positive count",
+			input: "undo 0",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "redo with too many arguments",
+			input: "redo 1 2",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "valid layer commands",
+			input: "layer new hud\nlayer use hud\nlayer hide hud\nlayer show hud\nlayer raise hud\nlayer lower hud\nlayer opacity hud 0.5",
+			expected: []painter.Operation{
+				painter.LayerOp{Name: "hud"},
+				painter.UseLayerOp{Name: "hud"},
+				painter.LayerVisibilityOp{Name: "hud", Hidden: true},
+				painter.LayerVisibilityOp{Name: "hud"},
+				painter.LayerRaiseOp{Name: "hud"},
+				painter.LayerLowerOp{Name: "hud"},
+				painter.LayerOpacityOp{Name: "hud", Opacity: 0.5},
+			},
+			expectError: false,
+		},
+		{
+			name: "layer opacity out of range",
+			input: "layer opacity hud 2",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "layer with unknown subcommand",
+			input: "layer merge hud",
+			expected: nil,
+			expectError: true,
+		},
 		{
 			name: "valid reset command",
 			input: "reset",
@@ -84,8 +400,221 @@ func TestParser_Parse(t *testing.T) {
 			expectError: false,
 		},
 		{
-			name: "unknown command",
+			name: "valid fill command with named color",
 			input: "fill red",
+			expected: []painter.Operation{painter.FillOp{Color: color.NRGBA{R: 255, A: 255}}},
+			expectError: false,
+		},
+		{
+			name: "valid fill command with spaced rgb color",
+			input: "fill rgb(0, 128, 255)",
+			expected: []painter.Operation{painter.FillOp{Color: color.NRGBA{G: 128, B: 255, A: 255}}},
+			expectError: false,
+		},
+		{
+			name: "valid gradient commands",
+			input: "gradient linear angle=90 red blue\ngradient radial center=0.25,0.75 radius=1 white@0.2 gray black@0.8\nrectgradient r1 linear \"rgb(0, 0, 0)\" white@0.5 black",
+			expected: []painter.Operation{
+				painter.GradientFillOp{Gradient: painter.NewLinearGradient(90, []painter.GradientStop{
+					{Offset: 0, Color: color.NRGBA{R: 0xff, A: 0xff}},
+					{Offset: 1, Color: color.NRGBA{B: 0xff, A: 0xff}},
+				})},
+				painter.GradientFillOp{Gradient: painter.NewRadialGradient(painter.Vec{X: 0.25, Y: 0.75}, 1, []painter.GradientStop{
+					{Offset: 0.2, Color: color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}},
+					{Offset: 0.5, Color: color.NRGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff}},
+					{Offset: 0.8, Color: color.NRGBA{A: 0xff}},
+				})},
+				painter.RectGradientOp{ID: "r1", Gradient: painter.NewLinearGradient(0, []painter.GradientStop{
+					{Offset: 0, Color: color.NRGBA{A: 0xff}},
+					{Offset: 0.5, Color: color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}},
+					{Offset: 1, Color: color.NRGBA{A: 0xff}},
+				})},
+			},
+			expectError: false,
+		},
+		{
+			name: "gradient with a single stop",
+			input: "gradient linear red",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "gradient with descending offsets",
+			input: "gradient linear red@0.8 blue@0.2",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "linear gradient with a radius",
+			input: "gradient linear radius=1 red blue",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "valid blend commands",
+			input: "blend fig1 multiply\nblend rect1 over",
+			expected: []painter.Operation{
+				painter.BlendOp{ID: "fig1", Mode: painter.BlendMultiply},
+				painter.BlendOp{ID: "rect1", Mode: painter.BlendOver},
+			},
+			expectError: false,
+		},
+		{
+			name: "blend with an unknown mode",
+			input: "blend fig1 burn",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "valid animate commands",
+			input: "animate move 0.3 0 duration=2s easing=ease-in-out\nanimate style fig1 color=white alpha=0.5\nanimate stop",
+			expected: []painter.Operation{
+				painter.AnimateOp{Tween: painter.MoveOp{X: 0.3, Y: 0}, Duration: 2 * time.Second, Easing: painter.EaseInOut},
+				painter.AnimateOp{Tween: painter.TweenList{
+					painter.ColorOp{ID: "fig1", Color: color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}},
+					painter.AlphaOp{ID: "fig1", Alpha: 0.5},
+				}, Duration: time.Second},
+				painter.StopAnimationsOp{},
+			},
+			expectError: false,
+		},
+		{
+			name: "animate a command that cannot be animated",
+			input: "animate remove fig1",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "animate with a bad duration",
+			input: "animate move 0.1 0.1 duration=-1s",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "animate with an unknown easing",
+			input: "animate move 0.1 0.1 easing=bounce",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "record commands",
+			input: "record start\nrecord stop",
+			expected: []painter.Operation{painter.RecordOp{}, painter.StopRecordingOp{}},
+			expectError: false,
+		},
+		{
+			name: "record without start or stop",
+			input: "record pause",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "block without a command",
+			input: "{\n}",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "valid timeline commands",
+			input: "timeline bounce mode=pingpong {\n  0s hero center=0.2,0.5 size=0.1\n\n  1.5s hero center=0.8,0.5 color=red alpha=0.5 easing=ease-out\n}\nplay bounce\npause bounce\nseek bounce 750ms",
+			expected: []painter.Operation{
+				painter.TimelineOp{Timeline: &painter.Timeline{Name: "bounce", Mode: painter.TimelinePingPong, Keyframes: []painter.Keyframe{
+					{At: 0, Figure: "hero", Center: &painter.Vec{X: 0.2, Y: 0.5}, Size: ptr(0.1)},
+					{At: 1500 * time.Millisecond, Figure: "hero", Center: &painter.Vec{X: 0.8, Y: 0.5}, Color: color.NRGBA{R: 0xff, A: 0xff}, Alpha: ptr(0.5), Easing: painter.EaseOut},
+				}}},
+				painter.PlayOp{Name: "bounce"},
+				painter.PauseOp{Name: "bounce"},
+				painter.SeekOp{Name: "bounce", At: 750 * time.Millisecond},
+			},
+			expectError: false,
+		},
+		{
+			name: "unclosed timeline block",
+			input: "timeline bounce {\n0s hero size=0.1",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "keyframe without properties",
+			input: "timeline bounce {\n0s hero\n}",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "timeline with an unknown mode",
+			input: "timeline bounce mode=shuffle {\n0s hero size=0.1\n}",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "block for a command without one",
+			input: "figure 0.5 0.5 {\n}",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "valid physics commands",
+			input: "velocity 0.1 -0.2\nvelocity fig1 0.5 0\nacceleration fig1 0 0.98\nboundary fig1 bounce\nboundary none",
+			expected: []painter.Operation{
+				painter.VelocityOp{X: 0.1, Y: -0.2},
+				painter.VelocityOp{ID: "fig1", X: 0.5, Y: 0},
+				painter.AccelerationOp{ID: "fig1", X: 0, Y: 0.98},
+				painter.BoundaryOp{ID: "fig1", Boundary: painter.BoundaryBounce},
+				painter.BoundaryOp{},
+			},
+			expectError: false,
+		},
+		{
+			name: "boundary with an unknown mode",
+			input: "boundary fig1 teleport",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "velocity with a missing component",
+			input: "velocity fig1 0.5",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "valid on collide command",
+			input: "on collide ball edge {\n  velocity ball 0 0\n  hide ball\n}\non collide ball * {\n}",
+			expected: []painter.Operation{
+				painter.OnCollideOp{A: "ball", B: painter.CollideEdge, Ops: painter.OperationList{
+					painter.VelocityOp{ID: "ball"},
+					painter.HideOp{ID: "ball"},
+				}},
+				painter.OnCollideOp{A: "ball", B: painter.CollideAny},
+			},
+			expectError: false,
+		},
+		{
+			name: "on collide with an invalid command in the block",
+			input: "on collide ball edge {\n  explode ball\n}",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "on collide starting with the edge",
+			input: "on collide edge ball {\n}",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "fill without color",
+			input: "fill",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "fill with unknown color",
+			input: "fill notacolor",
+			expected: nil,
+			expectError: true,
+		},
+		{
+			name: "unknown command",
+			input: "paint red",
 			expected: nil,
 			expectError: true,
 		},
@@ -129,6 +658,7 @@ func TestParser_Parse(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			var p Parser
 			reader := strings.NewReader(tt.input)
 			ops, err := p.Parse(reader)
 
@@ -152,6 +682,13 @@ func TestParser_Parse(t *testing.T) {
 						if _, ok := tt.expected[i].(painter.GreenOp); !ok {
 							t.Errorf("Operation type mismatch at index %d. Expected type: painter.GreenOp, Got received type: %T", i, receivedOp)
 						}
+					case painter.FillOp:
+						expectedOp, ok := tt.expected[i].(painter.FillOp)
+						if !ok {
+							t.Errorf("Operation type mismatch at index %d. Expected type: painter.FillOp, Got received type: %T", i, receivedOp)
+						} else if receivedOp != expectedOp {
+							t.Errorf("FillOp mismatch at index %d. Expected: %v, Got: %v", i, expectedOp, receivedOp)
+						}
 					case painter.BgRectOp:
 						expectedOp, ok := tt.expected[i].(painter.BgRectOp)
 						if !ok {
@@ -163,7 +700,7 @@ func TestParser_Parse(t *testing.T) {
 						expectedOp, ok := tt.expected[i].(painter.FigureOp)
 						if !ok {
 							t.Errorf("Operation type mismatch at index %d. Expected type: painter.FigureOp, Got received type: %T", i, receivedOp)
-						} else if receivedOp != expectedOp {
+						} else if !reflect.DeepEqual(receivedOp, expectedOp) {
 							t.Errorf("FigureOp mismatch at index %d. Expected: %v, Got: %v", i, expectedOp, receivedOp)
 						}
 					case painter.MoveOp:
@@ -173,6 +710,28 @@ func TestParser_Parse(t *testing.T) {
 						} else if receivedOp != expectedOp {
 							t.Errorf("MoveOp mismatch at index %d. Expected: %v, Got: %v", i, expectedOp, receivedOp)
 						}
+					case painter.MoveToOp:
+						expectedOp, ok := tt.expected[i].(painter.MoveToOp)
+						if !ok {
+							t.Errorf("Operation type mismatch at index %d. Expected type: painter.MoveToOp, Got received type: %T", i, receivedOp)
+						} else if receivedOp != expectedOp {
+							t.Errorf("MoveToOp mismatch at index %d. Expected: %v, Got: %v", i, expectedOp, receivedOp)
+						}
+					case painter.OperationList, painter.RotateOp, painter.ScaleOp, painter.PathOp,
+						painter.GradientFillOp, painter.RectGradientOp, painter.AnimateOp, painter.TimelineOp,
+						painter.OnCollideOp:
+						if !reflect.DeepEqual(receivedOp, tt.expected[i]) {
+							t.Errorf("Operation mismatch at index %d. Expected: %v, Got: %v", i, tt.expected[i], receivedOp)
+						}
+					case painter.RemoveOp, painter.HideOp, painter.ShowOp, painter.UndoOp, painter.RedoOp,
+						painter.RectColorOp, painter.RectRemoveOp, painter.RaiseOp, painter.LowerOp,
+						painter.LayerOp, painter.UseLayerOp, painter.LayerVisibilityOp, painter.LayerOpacityOp,
+						painter.LayerRaiseOp, painter.LayerLowerOp, painter.PathRemoveOp, painter.TextOp, painter.TextRemoveOp,
+						painter.BlendOp, painter.StopAnimationsOp, painter.PlayOp, painter.PauseOp, painter.SeekOp,
+						painter.VelocityOp, painter.AccelerationOp, painter.BoundaryOp, painter.RecordOp, painter.StopRecordingOp:
+						if receivedOp != tt.expected[i] {
+							t.Errorf("Operation mismatch at index %d. Expected: %v, Got: %v", i, tt.expected[i], receivedOp)
+						}
 					case painter.ResetOp:
 						if _, ok := tt.expected[i].(painter.ResetOp); !ok {
 							t.Errorf("Operation type mismatch at index %d. Expected type: painter.ResetOp, Got received type: %T", i, receivedOp)
@@ -191,3 +750,82 @@ func TestParser_Parse(t *testing.T) {
 		})
 	}
 }
+
+func TestParser_SceneCommands(t *testing.T) {
+	scenes := &painter.SceneStore{Dir: t.TempDir()}
+	p := Parser{Scenes: scenes}
+
+	ops, err := p.Parse(strings.NewReader("save demo\nload demo"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expected := []painter.Operation{
+		painter.SaveOp{Store: scenes, Name: "demo"},
+		painter.LoadOp{Store: scenes, Name: "demo"},
+	}
+	if !reflect.DeepEqual(ops, expected) {
+		t.Errorf("Expected: %v, Got: %v", expected, ops)
+	}
+
+	for _, input := range []string{"save ../demo", "load", "save a b"} {
+		if _, err := p.Parse(strings.NewReader(input)); err == nil {
+			t.Errorf("Expected an error for %q", input)
+		}
+	}
+
+	var unconfigured Parser
+	if _, err := unconfigured.Parse(strings.NewReader("save demo")); err == nil {
+		t.Error("Expected an error for save without scene storage")
+	}
+}
+
+func TestParser_SpriteCommands(t *testing.T) {
+	p := Parser{Assets: &painter.AssetStore{}}
+	p.Assets.Register("parser-logo", image.NewRGBA(image.Rect(0, 0, 1, 1)))
+
+	ops, err := p.Parse(strings.NewReader("sprite parser-logo 0.5 0.5\nsprite id=big parser-logo 0 0 0.25 0.5\nspriteremove big"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expected := []painter.Operation{
+		painter.SpriteOp{Asset: "parser-logo", X: 0.5, Y: 0.5},
+		painter.SpriteOp{ID: "big", Asset: "parser-logo", W: 0.25, H: 0.5},
+		painter.SpriteRemoveOp{ID: "big"},
+	}
+	if !reflect.DeepEqual(ops, expected) {
+		t.Errorf("Expected: %v, Got: %v", expected, ops)
+	}
+
+	for _, input := range []string{"sprite missing-asset 0 0", "sprite parser-logo 0 0 1", "sprite parser-logo 0 0 0 1"} {
+		if _, err := p.Parse(strings.NewReader(input)); err == nil {
+			t.Errorf("Expected an error for %q", input)
+		}
+	}
+	var unconfigured Parser
+	if _, err := unconfigured.Parse(strings.NewReader("sprite parser-logo 0 0")); err == nil {
+		t.Error("Expected an error for a sprite without asset storage")
+	}
+}
+
+func TestParser_InvalidIDs(t *testing.T) {
+	for _, input := range []string{
+		"figure id=* 0.5 0.5",
+		// Commas separate the figures of rotate and scale.
+		"figure id=a,b 0.5 0.5",
+		"rotate 45 id=a,,b",
+		"scale 2 id=a,*",
+		"bgrect id=a/b 0 0 1 1",
+		`line id="a b" 0 0 1 1`,
+		`text id=a.b 0.5 0.5 "hi"`,
+		"sprite id=edge logo 0.5 0.5",
+	} {
+		p := &Parser{}
+		if _, err := p.Parse(strings.NewReader(input)); err == nil {
+			t.Errorf("Expected an error for %q", input)
+		}
+	}
+}
+
+func ptr(v float64) *float64 {
+	return &v
+}
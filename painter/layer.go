@@ -0,0 +1,156 @@
+package painter
+
+import (
+	"image/color"
+	"math"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+const DefaultLayer = "default"
+
+// Layer groups background rects and figures. Layers later in State.Layers
+// are composited on top of earlier ones, with Opacity applied to the layer as
+// a whole.
+type Layer struct {
+	Name    string
+	Hidden  bool
+	Opacity float64
+}
+
+func NewLayer(name string) Layer {
+	return Layer{Name: name, Opacity: 1}
+}
+
+func (s *State) layerIndex(name string) int {
+	for i := range s.Layers {
+		if s.Layers[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *State) ensureLayer(name string) *Layer {
+	if i := s.layerIndex(name); i >= 0 {
+		return &s.Layers[i]
+	}
+	s.Layers = append(s.Layers, NewLayer(name))
+	return &s.Layers[len(s.Layers)-1]
+}
+
+// drawingLayers returns the layers in drawing order, falling back to the
+// default layer for states built without any.
+func (s *State) drawingLayers() []Layer {
+	if len(s.Layers) == 0 {
+		return []Layer{NewLayer(DefaultLayer)}
+	}
+	return s.Layers
+}
+
+func (s *State) currentLayer() string {
+	if s.CurrentLayer == "" {
+		return DefaultLayer
+	}
+	return s.CurrentLayer
+}
+
+// layerShown reports whether Render draws the elements of the layer.
+func (s *State) layerShown(name string) bool {
+	if name == "" {
+		name = DefaultLayer
+	}
+	for _, l := range s.drawingLayers() {
+		if l.Name == name {
+			return !l.Hidden && l.Opacity > 0
+		}
+	}
+	return false
+}
+
+func onLayer(elementLayer string, l Layer) bool {
+	if elementLayer == "" {
+		elementLayer = DefaultLayer
+	}
+	return elementLayer == l.Name
+}
+
+func fadeColor(c color.Color, alpha float64) color.Color {
+	if alpha >= 1 {
+		return c
+	}
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	n.A = uint8(math.Round(float64(n.A) * math.Max(0, alpha)))
+	return n
+}
+
+// LayerOp creates a layer on top of the existing ones.
+type LayerOp struct {
+	Name string
+}
+
+func (op LayerOp) Do(t screen.Texture, s *State) bool {
+	s.ensureLayer(op.Name)
+	return false
+}
+
+// UseLayerOp makes subsequent figures and bg rects go to the named layer,
+// creating it when needed.
+type UseLayerOp struct {
+	Name string
+}
+
+func (op UseLayerOp) Do(t screen.Texture, s *State) bool {
+	s.ensureLayer(op.Name)
+	s.CurrentLayer = op.Name
+	return false
+}
+
+type LayerVisibilityOp struct {
+	Name   string
+	Hidden bool
+}
+
+func (op LayerVisibilityOp) Do(t screen.Texture, s *State) bool {
+	if i := s.layerIndex(op.Name); i >= 0 {
+		s.Layers[i].Hidden = op.Hidden
+	}
+	return false
+}
+
+type LayerOpacityOp struct {
+	Name    string
+	Opacity float64
+}
+
+func (op LayerOpacityOp) Do(t screen.Texture, s *State) bool {
+	if i := s.layerIndex(op.Name); i >= 0 {
+		s.Layers[i].Opacity = op.Opacity
+	}
+	return false
+}
+
+type LayerRaiseOp struct {
+	Name string
+}
+
+func (op LayerRaiseOp) Do(t screen.Texture, s *State) bool {
+	if i := s.layerIndex(op.Name); i >= 0 {
+		layer := s.Layers[i]
+		s.Layers = append(append(s.Layers[:i], s.Layers[i+1:]...), layer)
+	}
+	return false
+}
+
+type LayerLowerOp struct {
+	Name string
+}
+
+func (op LayerLowerOp) Do(t screen.Texture, s *State) bool {
+	if i := s.layerIndex(op.Name); i >= 0 {
+		layer := s.Layers[i]
+		copy(s.Layers[1:i+1], s.Layers[:i])
+		s.Layers[0] = layer
+	}
+	return false
+}
@@ -1,7 +1,10 @@
 package painter
 
 import (
+	"context"
+	"errors"
 	"image"
+	"log"
 	"sync"
 	"time"
 
@@ -15,13 +18,37 @@ type Receiver interface {
 type Loop struct {
 	Receiver Receiver
 
-	next screen.Texture
-	prev screen.Texture
+	next   screen.Texture
+	prev   screen.Texture
+	buffer screen.Buffer
 
 	mq messageQueue
 
 	State *State
 
+	// Assets provides the images drawn by sprites. Sprites are skipped when
+	// it is nil.
+	Assets *AssetStore
+
+	// HistoryDepth bounds the number of batches that can be undone.
+	// DefaultHistoryDepth is used when it is not set.
+	HistoryDepth int
+	history      history
+
+	// FPS is the frame rate at which animations are advanced and drawn.
+	// DefaultFPS is used when it is not set.
+	FPS        int
+	animations []*animation
+	timelines  timelines
+	lastTick   time.Time
+	collisions collisions
+	recorder   recorder
+	frames     frameStream
+
+	screen screen.Screen
+	drawn  bool
+	stale  []screen.Texture
+
 	stop chan struct{}
 	Done chan struct{}
 	stopReq bool
@@ -29,7 +56,7 @@ type Loop struct {
 	loopRunning bool
 }
 
-var size = image.Pt(800, 800)
+var defaultSize = image.Pt(800, 800)
 
 func (l *Loop) Start(s screen.Screen) {
 	l.mu.Lock()
@@ -40,12 +67,15 @@ func (l *Loop) Start(s screen.Screen) {
 	l.loopRunning = true
 	l.mu.Unlock()
 
-	l.next, _ = s.NewTexture(size)
-	l.prev, _ = s.NewTexture(size)
+	l.screen = s
+	l.next, _ = s.NewTexture(defaultSize)
+	l.prev, _ = s.NewTexture(defaultSize)
+	l.buffer, _ = s.NewBuffer(defaultSize)
 	l.mq = messageQueue{queue: make(chan Operation, 1000)}
 	l.stop = make(chan struct{})
 	l.Done = make(chan struct{})
 	l.State = DefaultState()
+	l.history = history{depth: l.HistoryDepth}
 
 	go l.run()
 }
@@ -54,6 +84,14 @@ func (l *Loop) Post(op Operation) {
 	l.mq.push(op)
 }
 
+// Resize makes the loop render into textures of the given size from now on.
+func (l *Loop) Resize(size image.Point) {
+	if size.X <= 0 || size.Y <= 0 {
+		return
+	}
+	l.Post(resizeOp{loop: l, size: size})
+}
+
 func (l *Loop) StopAndWait() {
 	l.mu.Lock()
 	if !l.loopRunning {
@@ -80,8 +118,19 @@ func (l *Loop) run() {
 		if l.prev != nil {
 			l.prev.Release()
 		}
+		if l.buffer != nil {
+			l.buffer.Release()
+		}
+		l.releaseStale()
 	}()
 
+	fps := l.FPS
+	if fps <= 0 {
+		fps = DefaultFPS
+	}
+	clock := time.NewTicker(time.Second / time.Duration(fps))
+	defer clock.Stop()
+
 	for {
 		l.mu.Lock()
 		if l.stopReq && l.mq.empty() {
@@ -92,17 +141,385 @@ func (l *Loop) run() {
 
 		select {
 		case op := <-l.mq.queue:
-			needsUpdate := op.Do(l.next, l.State)
+			needsUpdate := l.execute(op)
 
 			if needsUpdate {
-				DrawStateOp{}.Do(l.next, l.State)
-				l.Receiver.Update(l.next)
-				l.next, l.prev = l.prev, l.next
+				l.draw()
+			}
+
+		case now := <-clock.C:
+			if l.tick(now) {
+				l.draw()
+			}
+		}
+	}
+}
+
+func (l *Loop) draw() {
+	DrawStateOp{Buffer: l.buffer, Assets: l.Assets}.Do(l.next, l.State)
+	l.recorder.capture(l.buffer.RGBA(), time.Now())
+	l.frames.publish(l.buffer.RGBA())
+	l.Receiver.Update(l.next)
+	l.next, l.prev = l.prev, l.next
+	l.drawn = true
+	l.releaseStale()
+}
+
+// tick advances the moving figures, the playing timelines and the running
+// animations, runs the handlers of new collisions and reports whether
+// anything changed.
+//
+// These changes are not undoable batches of their own. Undo restores the
+// state a posted batch started from, so it also drops whatever ticks have
+// changed since that batch, while changes made before it are kept.
+func (l *Loop) tick(now time.Time) bool {
+	var dt time.Duration
+	if !l.lastTick.IsZero() {
+		dt = now.Sub(l.lastTick)
+	}
+	l.lastTick = now
+
+	advanced := l.State.step(dt.Seconds(), l.next.Size())
+	advanced = l.timelines.advance(l.State, now) || advanced
+	if len(l.animations) > 0 {
+		running := l.animations[:0]
+		for _, a := range l.animations {
+			if !a.advance(l.State, now) {
+				running = append(running, a)
 			}
+		}
+		l.animations = running
+		advanced = true
+	}
+
+	if !l.collisions.watched() {
+		return advanced
+	}
+	for _, c := range l.collisions.detect(l.State, l.next.Size()) {
+		l.collisions.publish(c)
+		for _, h := range l.collisions.handlers {
+			if h.matches(c) {
+				if _, _, err := l.apply(h.Ops); err != nil {
+					log.Printf("Collision handler failed: %s", err)
+				}
+				advanced = true
+			}
+		}
+	}
+	return advanced
+}
+
+// Subscribe returns a channel that receives the collisions detected from now
+// on. Events are dropped for subscribers that do not keep up.
+func (l *Loop) Subscribe() <-chan Collision {
+	return l.collisions.subscribe()
+}
+
+// Unsubscribe closes a channel returned by Subscribe.
+func (l *Loop) Unsubscribe(ch <-chan Collision) {
+	l.collisions.unsubscribe(ch)
+}
+
+// ErrNotRunning is returned for requests that need a running Loop.
+var ErrNotRunning = errors.New("loop is not running")
+
+// Snapshot renders the current state into a new image of the given size, or
+// of the size of the canvas when size is zero.
+func (l *Loop) Snapshot(ctx context.Context, size image.Point) (*image.RGBA, error) {
+	var (
+		img *image.RGBA
+		err error
+	)
+	if callErr := l.call(ctx, func() { img, err = l.snapshot(size) }); callErr != nil {
+		return nil, callErr
+	}
+	return img, err
+}
+
+// SubscribeFrames returns a channel that receives a copy of every frame drawn
+// from now on. The frames must not be modified. A subscriber that does not
+// keep up only gets the latest frame.
+func (l *Loop) SubscribeFrames() <-chan *image.RGBA {
+	return l.frames.subscribe()
+}
+
+// UnsubscribeFrames closes a channel returned by SubscribeFrames.
+func (l *Loop) UnsubscribeFrames(ch <-chan *image.RGBA) {
+	l.frames.unsubscribe(ch)
+}
 
-		case <-time.After(time.Millisecond * 100):
+// Recording returns the last recording as an animated GIF, waiting for it to
+// be encoded.
+func (l *Loop) Recording(ctx context.Context) ([]byte, error) {
+	// The operations posted so far are handled first, so a recording stopped
+	// by one of them is not missed.
+	if err := l.call(ctx, func() {}); err != nil && err != ErrNotRunning {
+		return nil, err
+	}
+	return l.recorder.result(ctx)
+}
+
+// Inspect returns a copy of the current state and the size of the canvas it
+// is drawn on.
+func (l *Loop) Inspect(ctx context.Context) (*State, image.Point, error) {
+	var (
+		s    *State
+		size image.Point
+	)
+	if err := l.call(ctx, func() { s, size = l.State.Clone(), l.next.Size() }); err != nil {
+		return nil, image.Point{}, err
+	}
+	return s, size, nil
+}
+
+// Added lists the IDs of the elements a batch of operations has added,
+// including those the state has assigned to elements posted without an ID.
+type Added struct {
+	Figures, Rects, Paths, Texts, Sprites []string
+}
+
+func added(before, after *State) Added {
+	return Added{
+		Figures: newIDs(before.Figures, after.Figures, func(f Figure) string { return f.ID }),
+		Rects:   newIDs(before.BgRects, after.BgRects, func(r BgRect) string { return r.ID }),
+		Paths:   newIDs(before.Paths, after.Paths, func(p Path) string { return p.ID }),
+		Texts:   newIDs(before.Texts, after.Texts, func(t Text) string { return t.ID }),
+		Sprites: newIDs(before.Sprites, after.Sprites, func(sp Sprite) string { return sp.ID }),
+	}
+}
+
+func newIDs[T any](before, after []T, id func(T) string) []string {
+	existing := map[string]bool{}
+	for _, e := range before {
+		existing[id(e)] = true
+	}
+	var ids []string
+	for _, e := range after {
+		if !existing[id(e)] {
+			ids = append(ids, id(e))
 		}
 	}
+	return ids
+}
+
+// Execute posts a batch of operations like Post, waits for the Loop to run
+// it and reports what it has added. The error of the first FallibleOp that
+// failed is returned along with it.
+func (l *Loop) Execute(ctx context.Context, op Operation) (Added, error) {
+	res := &reportOp{Operation: op, done: make(chan struct{})}
+	if err := l.await(ctx, res, res.done); err != nil {
+		return Added{}, err
+	}
+	return res.added, res.err
+}
+
+// call runs fn in the loop goroutine, between two batches of operations, and
+// waits for it to finish.
+func (l *Loop) call(ctx context.Context, fn func()) error {
+	done := make(chan struct{})
+	return l.await(ctx, callOp{fn: fn, done: done}, done)
+}
+
+// await posts op and waits until done is closed.
+func (l *Loop) await(ctx context.Context, op Operation, done <-chan struct{}) error {
+	l.mu.Lock()
+	running := l.loopRunning && !l.stopReq
+	l.mu.Unlock()
+	if !running {
+		return ErrNotRunning
+	}
+
+	l.Post(op)
+	select {
+	case <-done:
+		return nil
+	case <-l.Done:
+		return ErrNotRunning
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// execute runs a posted batch of operations and records the state it started
+// from, so the whole batch can be undone at once.
+func (l *Loop) execute(op Operation) bool {
+	switch op.(type) {
+	case callOp, resizeOp:
+		// Requests of the Loop itself leave the state alone.
+		needsUpdate, _, _ := l.apply(op)
+		return needsUpdate
+	}
+
+	report, _ := op.(*reportOp)
+	if report != nil {
+		op = report.Operation
+	}
+
+	var before *State
+	if mutates(op) {
+		before = l.State.Clone()
+	}
+	needsUpdate, travelled, err := l.apply(op)
+	if before != nil && !travelled {
+		l.history.push(before)
+	}
+	if report != nil {
+		if before != nil {
+			report.added = added(before, l.State)
+		}
+		report.err = err
+		close(report.done)
+	} else if err != nil {
+		log.Print(err)
+	}
+	return needsUpdate
+}
+
+// apply runs op against the state. Batches run to the end even when one of
+// their operations fails; the first failure is returned.
+func (l *Loop) apply(op Operation) (needsUpdate, travelled bool, err error) {
+	switch op := op.(type) {
+	case OperationList:
+		for _, o := range op {
+			u, tr, e := l.apply(o)
+			needsUpdate = needsUpdate || u
+			travelled = travelled || tr
+			if err == nil {
+				err = e
+			}
+		}
+		return needsUpdate, travelled, err
+	case UndoOp:
+		l.State = l.history.back(l.State, op.N)
+		return false, true, nil
+	case RedoOp:
+		l.State = l.history.forward(l.State, op.N)
+		return false, true, nil
+	case AnimateOp:
+		l.animations = append(l.animations, &animation{AnimateOp: op, start: time.Now()})
+		return false, false, nil
+	case StopAnimationsOp:
+		l.animations = nil
+		return false, false, nil
+	case TimelineOp:
+		l.timelines.define(op.Timeline)
+		return false, false, nil
+	case PlayOp:
+		l.timelines.play(op.Name, time.Now())
+		return false, false, nil
+	case PauseOp:
+		l.timelines.pause(op.Name, time.Now())
+		return false, false, nil
+	case SeekOp:
+		return l.timelines.seek(l.State, op.Name, op.At, time.Now()), false, nil
+	case OnCollideOp:
+		l.collisions.handle(op)
+		return false, false, nil
+	case RecordOp:
+		l.recorder.start()
+		if l.drawn {
+			l.recorder.capture(l.buffer.RGBA(), time.Now())
+		}
+		return false, false, nil
+	case StopRecordingOp:
+		l.recorder.stop(time.Now())
+		return false, false, nil
+	case ResetOp:
+		l.animations, l.timelines = nil, nil
+		l.collisions.handlers, l.collisions.touching = nil, nil
+		return op.Do(l.next, l.State), false, nil
+	case FallibleOp:
+		return false, false, op.Run(l.State)
+	default:
+		return op.Do(l.next, l.State), false, nil
+	}
+}
+
+type resizeOp struct {
+	loop *Loop
+	size image.Point
+}
+
+func (op resizeOp) Do(t screen.Texture, s *State) bool {
+	l := op.loop
+	if l.next.Size() == op.size {
+		return false
+	}
+
+	next, err := l.screen.NewTexture(op.size)
+	if err != nil {
+		return false
+	}
+	prev, err := l.screen.NewTexture(op.size)
+	if err != nil {
+		next.Release()
+		return false
+	}
+	buffer, err := l.screen.NewBuffer(op.size)
+	if err != nil {
+		next.Release()
+		prev.Release()
+		return false
+	}
+	l.buffer.Release()
+	l.buffer = buffer
+
+	// The receiver may still be showing prev, so it is only released once a
+	// frame rendered at the new size has been delivered.
+	l.next.Release()
+	l.stale = append(l.stale, l.prev)
+	l.next, l.prev = next, prev
+	if !l.drawn {
+		l.releaseStale()
+	}
+	return l.drawn
+}
+
+// reportOp wraps a batch run by Execute.
+type reportOp struct {
+	Operation
+	added Added
+	err   error
+	done  chan struct{}
+}
+
+type callOp struct {
+	fn   func()
+	done chan<- struct{}
+}
+
+func (op callOp) Do(t screen.Texture, s *State) bool {
+	op.fn()
+	close(op.done)
+	return false
+}
+
+func (l *Loop) snapshot(size image.Point) (*image.RGBA, error) {
+	if size == (image.Point{}) {
+		size = l.next.Size()
+	}
+	texture, err := l.screen.NewTexture(size)
+	if err != nil {
+		return nil, err
+	}
+	defer texture.Release()
+	buffer, err := l.screen.NewBuffer(size)
+	if err != nil {
+		return nil, err
+	}
+	defer buffer.Release()
+
+	DrawStateOp{Buffer: buffer, Assets: l.Assets}.Do(texture, l.State)
+	img := image.NewRGBA(buffer.Bounds())
+	copy(img.Pix, buffer.RGBA().Pix)
+	return img, nil
+}
+
+func (l *Loop) releaseStale() {
+	for _, t := range l.stale {
+		t.Release()
+	}
+	l.stale = nil
 }
 
 type messageQueue struct {
@@ -1,12 +1,17 @@
 package painter
 
 import (
+	"bytes"
+	"context"
 	"image"
 	"image/color"
-	"image/draw"
+	"image/gif"
+	"math"
+	"slices"
 	"testing"
 	"time"
 
+	"github.com/maxnetyaga/software-architecture-lab3/ui/headless"
 	"golang.org/x/exp/shiny/screen"
 )
 
@@ -23,47 +28,15 @@ func (tr *testReceiver) Update(t screen.Texture) {
 	}
 }
 
-type mockScreen struct{}
-
-func (m mockScreen) NewBuffer(size image.Point) (screen.Buffer, error) {
-	panic("implement me")
-}
-
-func (m mockScreen) NewTexture(size image.Point) (screen.Texture, error) {
-	return &mockTexture{size: size, buffer: image.NewRGBA(image.Rectangle{Max: size})}, nil
-}
-
-func (m mockScreen) NewWindow(opts *screen.NewWindowOptions) (screen.Window, error) {
-	panic("implement me")
-}
-
-type mockTexture struct {
-	size   image.Point
-	buffer *image.RGBA
-}
-
-func (m *mockTexture) Release() {}
-
-func (m *mockTexture) Size() image.Point { return m.size }
-
-func (m *mockTexture) Bounds() image.Rectangle {
-	return image.Rectangle{Max: m.size}
-}
-
-func (m *mockTexture) Upload(dp image.Point, src screen.Buffer, sr image.Rectangle) {}
-
-func (m *mockTexture) Fill(dr image.Rectangle, src color.Color, op draw.Op) {
-	draw.Draw(m.buffer, dr, image.NewUniform(src), image.Point{}, op)
-}
-
 func checkPixelColor(t *testing.T, texture screen.Texture, x, y int, expected color.Color, message string) {
-	mt, ok := texture.(*mockTexture)
+	ht, ok := texture.(*headless.Texture)
 	if !ok {
-		t.Errorf("Expected *mockTexture, got %T", texture)
+		t.Errorf("Expected *headless.Texture, got %T", texture)
 		return
 	}
+	pixels := ht.RGBA()
 
-	gotR, gotG, gotB, gotA := mt.buffer.At(x, y).RGBA()
+	gotR, gotG, gotB, gotA := pixels.At(x, y).RGBA()
 	expectedR, expectedG, expectedB, expectedA := expected.RGBA()
 
 	if gotR != expectedR || gotG != expectedG || gotB != expectedB || gotA != expectedA {
@@ -71,7 +44,7 @@ func checkPixelColor(t *testing.T, texture screen.Texture, x, y int, expected co
 			message, x, y,
 			color.RGBA64{R: uint16(expectedR), G: uint16(expectedG), B: uint16(expectedB), A: uint16(expectedA)},
 			color.RGBA64{R: uint16(gotR), G: uint16(gotG), B: uint16(gotB), A: uint16(gotA)},
-			mt.buffer.At(x, y),
+			pixels.At(x, y),
 		)
 	}
 }
@@ -84,8 +57,7 @@ func TestLoop_PostAndRun(t *testing.T) {
 	tr.updated = make(chan struct{}, 1)
 	l.Receiver = &tr
 
-	mockSc := mockScreen{}
-	l.Start(mockSc)
+	l.Start(headless.Screen{})
 	defer l.StopAndWait()
 
 	t.Run("WhiteBackground", func(t *testing.T) {
@@ -216,4 +188,291 @@ func TestLoop_PostAndRun(t *testing.T) {
 			t.Fatal("Timeout waiting for texture update after ResetOp")
 		}
 	})
-}
\ No newline at end of file
+
+	t.Run("Resize", func(t *testing.T) {
+		l.Post(ResetOp{})
+		l.Post(WhiteOp{})
+		l.Post(FigureOp{X: 0.5, Y: 0.5})
+		l.Post(UpdateOp)
+
+		select {
+		case <-tr.updated:
+		case <-time.After(time.Second):
+			t.Fatal("Timeout drawing initial state for Resize test")
+		}
+
+		l.Resize(image.Pt(400, 200))
+
+		select {
+		case <-tr.updated:
+			if got := tr.lastTexture.Size(); got != image.Pt(400, 200) {
+				t.Fatalf("Expected texture of size 400x200 after resize, got %v", got)
+			}
+			figureColor := color.RGBA{R: 255, G: 255, B: 0, A: 255}
+			checkPixelColor(t, tr.lastTexture, 200, 100, figureColor, "Figure is not centered after resize")
+			checkPixelColor(t, tr.lastTexture, 10, 10, color.White, "Background is not redrawn after resize")
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for texture update after Resize")
+		}
+	})
+
+	t.Run("UndoRedo", func(t *testing.T) {
+		// Textures are still 400x200 after the Resize subtest.
+		l.Post(OperationList{ResetOp{}, WhiteOp{}, FigureOp{ID: "a", X: 0.5, Y: 0.5}})
+		l.Post(OperationList{MoveOp{X: 0.25, Y: 0}, GreenOp{}})
+		l.Post(OperationList{UndoOp{N: 1}, UpdateOp})
+
+		select {
+		case <-tr.updated:
+			figureColor := color.RGBA{R: 255, G: 255, B: 0, A: 255}
+			checkPixelColor(t, tr.lastTexture, 100, 100, color.White, "Background was not restored by undo")
+			checkPixelColor(t, tr.lastTexture, 200, 100, figureColor, "Figure was not moved back by undo")
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for texture update after UndoOp")
+		}
+
+		l.Post(OperationList{RedoOp{N: 1}, UpdateOp})
+
+		select {
+		case <-tr.updated:
+			greenColor := color.RGBA{G: 255, A: 255}
+			checkPixelColor(t, tr.lastTexture, 100, 100, greenColor, "Background was not reapplied by redo")
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for texture update after RedoOp")
+		}
+	})
+
+	t.Run("Collision", func(t *testing.T) {
+		// Textures are still 400x200 after the Resize subtest.
+		events := l.Subscribe()
+		defer l.Unsubscribe(events)
+
+		l.Post(OperationList{
+			ResetOp{}, WhiteOp{},
+			FigureOp{ID: "a", X: 0.25, Y: 0.5}, FigureOp{ID: "b", X: 0.75, Y: 0.5},
+			OnCollideOp{A: "b", B: "a", Ops: OperationList{VelocityOp{ID: "a"}, HideOp{ID: "b"}}},
+			VelocityOp{ID: "a", X: 2},
+		})
+
+		select {
+		case event := <-events:
+			if event != (Collision{A: "a", B: "b"}) {
+				t.Fatalf("Unexpected collision %v", event)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for the collision event")
+		}
+
+		// The event is published while the loop handles the collision, so
+		// every frame drawn from now on shows the effect of the handler.
+		select {
+		case <-tr.updated:
+		default:
+		}
+		l.Post(UpdateOp)
+
+		select {
+		case <-tr.updated:
+			checkPixelColor(t, tr.lastTexture, 300, 100, color.White, "Collision handler did not hide the figure")
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for texture update after the collision")
+		}
+	})
+
+	t.Run("Snapshot", func(t *testing.T) {
+		l.Post(OperationList{ResetOp{}, WhiteOp{}, BgRectOp{ID: "r", X1: 0, Y1: 0, X2: 0.5, Y2: 1}})
+
+		img, err := l.Snapshot(context.Background(), image.Point{})
+		if err != nil {
+			t.Fatalf("Snapshot failed: %s", err)
+		}
+		if got := img.Bounds().Size(); got != image.Pt(400, 200) {
+			t.Errorf("Expected a snapshot of the canvas size, got %v", got)
+		}
+		checkSnapshotColor(t, img, 100, 100, color.Black)
+		checkSnapshotColor(t, img, 300, 100, color.White)
+
+		img, err = l.Snapshot(context.Background(), image.Pt(40, 20))
+		if err != nil {
+			t.Fatalf("Snapshot failed: %s", err)
+		}
+		if got := img.Bounds().Size(); got != image.Pt(40, 20) {
+			t.Errorf("Expected a snapshot of the requested size, got %v", got)
+		}
+		checkSnapshotColor(t, img, 10, 10, color.Black)
+		checkSnapshotColor(t, img, 30, 10, color.White)
+	})
+
+	t.Run("Recording", func(t *testing.T) {
+		l.Post(RecordOp{})
+		l.Post(OperationList{GreenOp{}, UpdateOp})
+		l.Post(OperationList{WhiteOp{}, UpdateOp})
+		l.Post(StopRecordingOp{})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		data, err := l.Recording(ctx)
+		if err != nil {
+			t.Fatalf("Recording failed: %s", err)
+		}
+		anim, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Failed to decode the recording: %s", err)
+		}
+		if len(anim.Image) == 0 {
+			t.Fatal("Recording has no frames")
+		}
+		last := anim.Image[len(anim.Image)-1]
+		if got := last.At(350, 100); !sameColor(got, color.White) {
+			t.Errorf("Expected the last frame to be white, got %v", got)
+		}
+		if got := last.Bounds().Size(); got != image.Pt(400, 200) {
+			t.Errorf("Expected frames of the canvas size, got %v", got)
+		}
+	})
+
+	t.Run("FrameStream", func(t *testing.T) {
+		frames := l.SubscribeFrames()
+		defer l.UnsubscribeFrames(frames)
+
+		l.Post(OperationList{GreenOp{}, UpdateOp})
+		select {
+		case frame := <-frames:
+			checkSnapshotColor(t, frame, 350, 100, color.RGBA{G: 255, A: 255})
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for a streamed frame")
+		}
+	})
+}
+
+func checkSnapshotColor(t *testing.T, img *image.RGBA, x, y int, expected color.Color) {
+	t.Helper()
+	if got, want := img.RGBAAt(x, y), color.RGBAModel.Convert(expected); got != want {
+		t.Errorf("Snapshot pixel at (%d, %d) = %v, want %v", x, y, got, want)
+	}
+}
+
+func TestLoop_SnapshotNotRunning(t *testing.T) {
+	var l Loop
+	if _, err := l.Snapshot(context.Background(), image.Point{}); err != ErrNotRunning {
+		t.Errorf("Expected ErrNotRunning, got %v", err)
+	}
+	if _, err := l.Recording(context.Background()); err != ErrNoRecording {
+		t.Errorf("Expected ErrNoRecording, got %v", err)
+	}
+}
+
+func TestLoop_Execute(t *testing.T) {
+	var l Loop
+	l.Receiver = &headless.Receiver{}
+	l.Start(headless.Screen{})
+	defer l.StopAndWait()
+
+	ctx := context.Background()
+	if _, err := l.Execute(ctx, FigureOp{ID: "f1", X: 0.2, Y: 0.2}); err != nil {
+		t.Fatalf("Execute failed: %s", err)
+	}
+	added, err := l.Execute(ctx, OperationList{FigureOp{X: 0.5, Y: 0.5}, BgRectOp{X2: 0.5, Y2: 0.5}})
+	if err != nil {
+		t.Fatalf("Execute failed: %s", err)
+	}
+	if !slices.Equal(added.Figures, []string{"f2"}) || !slices.Equal(added.Rects, []string{"r1"}) {
+		t.Errorf("Unexpected added elements %+v", added)
+	}
+
+	s, _, err := l.Inspect(ctx)
+	if err != nil {
+		t.Fatalf("Inspect failed: %s", err)
+	}
+	if len(s.Figures) != 2 {
+		t.Errorf("Expected the figure without an ID to be added, got %d figures", len(s.Figures))
+	}
+}
+
+// newTickLoop returns a Loop that is not running, so that its frame clock can
+// be driven by calling tick with synthetic times.
+func newTickLoop(t *testing.T) *Loop {
+	next, err := headless.Screen{}.NewTexture(image.Pt(400, 200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Loop{State: DefaultState(), next: next}
+}
+
+func TestLoop_Tick(t *testing.T) {
+	start := time.Now()
+
+	t.Run("Animation", func(t *testing.T) {
+		l := newTickLoop(t)
+		l.apply(FigureOp{ID: "a", X: 0.25, Y: 0.5})
+		l.animations = []*animation{{
+			AnimateOp: AnimateOp{Tween: MoveOp{ID: "a", X: 0.5}, Duration: 100 * time.Millisecond, Easing: EaseInOut},
+			start:     start,
+		}}
+
+		if !l.tick(start) || !l.tick(start.Add(50*time.Millisecond)) {
+			t.Fatal("Running animation was not reported")
+		}
+		if x := l.State.Figure("a").Center.X; math.Abs(x-0.5) > 1e-9 {
+			t.Errorf("Expected the figure halfway to its target, got x = %v", x)
+		}
+		l.tick(start.Add(200 * time.Millisecond))
+		if x := l.State.Figure("a").Center.X; math.Abs(x-0.75) > 1e-9 {
+			t.Errorf("Expected the figure at the end of the animation, got x = %v", x)
+		}
+		if len(l.animations) != 0 {
+			t.Error("Finished animation was kept")
+		}
+		if l.tick(start.Add(300 * time.Millisecond)) {
+			t.Error("Tick without animations reported a change")
+		}
+	})
+
+	t.Run("Physics", func(t *testing.T) {
+		l := newTickLoop(t)
+		l.apply(OperationList{FigureOp{ID: "a", X: 0.25, Y: 0.5}, VelocityOp{ID: "a", X: 4}, BoundaryOp{ID: "a", Boundary: BoundaryStop}})
+
+		l.tick(start)
+		if !l.tick(start.Add(50 * time.Millisecond)) {
+			t.Fatal("Moving figure was not reported")
+		}
+		if x := l.State.Figure("a").Center.X; math.Abs(x-0.45) > 1e-9 {
+			t.Errorf("Expected the figure to move by its velocity, got x = %v", x)
+		}
+		l.tick(start.Add(350 * time.Millisecond))
+		f := l.State.Figure("a")
+		if f.Center.X <= 0.9 || f.Center.X >= 1 || f.Velocity != (Vec{}) {
+			t.Errorf("Expected the figure to stop at the edge, got %v moving at %v", f.Center, f.Velocity)
+		}
+		if l.tick(start.Add(400 * time.Millisecond)) {
+			t.Error("Tick with a stopped figure reported a change")
+		}
+	})
+
+	t.Run("Undo", func(t *testing.T) {
+		l := newTickLoop(t)
+		l.execute(OperationList{FigureOp{ID: "a", X: 0.25, Y: 0.5}, VelocityOp{ID: "a", X: 1}})
+		l.tick(start)
+		l.tick(start.Add(100 * time.Millisecond))
+		l.execute(ColorOp{ID: "a", Color: color.White})
+		l.tick(start.Add(200 * time.Millisecond))
+
+		// Undo goes back to the state the color batch started from, keeping
+		// the tick before it and dropping the one after it.
+		l.execute(UndoOp{N: 1})
+		f := l.State.Figure("a")
+		if f == nil || math.Abs(f.Center.X-0.35) > 1e-9 || f.Color == color.White {
+			t.Fatalf("Expected the figure from before the color batch, got %+v", f)
+		}
+		// The frame clock carries on from the restored position.
+		l.tick(start.Add(300 * time.Millisecond))
+		if f := l.State.Figure("a"); math.Abs(f.Center.X-0.45) > 1e-9 {
+			t.Errorf("Expected the restored figure to keep moving, got x = %v", f.Center.X)
+		}
+
+		l.execute(UndoOp{N: 1})
+		if len(l.State.Figures) != 0 {
+			t.Errorf("Ticks were recorded as batches: %d figures left", len(l.State.Figures))
+		}
+	})
+}
@@ -1,23 +1,148 @@
 package painter
 
 import (
+	"fmt"
 	"image"
 	"image/color"
-	"image/draw"
 
 	"golang.org/x/exp/shiny/screen"
 )
 
+var DefaultFigureColor color.Color = color.RGBA{R: 0xff, G: 0xff, B: 0x00, A: 0xff}
+
+// DefaultFigureSize is relative to the smaller side of the texture.
+const DefaultFigureSize = 0.25
+
+// Rect is stored in coordinates relative to the texture size.
+type Rect struct {
+	Min Vec `json:"min"`
+	Max Vec `json:"max"`
+}
+
+func (r Rect) pixels(size image.Point) image.Rectangle {
+	return image.Rect(
+		int(r.Min.X*float64(size.X)), int(r.Min.Y*float64(size.Y)),
+		int(r.Max.X*float64(size.X)), int(r.Max.Y*float64(size.Y)),
+	)
+}
+
+// BgRect is a background rectangle. Rectangles later in State.BgRects are
+// painted on top of earlier ones.
+type BgRect struct {
+	ID string
+	Rect
+	Color color.Color
+	// Gradient, when set, is painted instead of Color.
+	Gradient *Gradient
+	Blend    BlendMode
+	Layer    string
+}
+
+// Figure centers are relative to the texture size, so the state can be
+// rendered at any resolution.
+type Figure struct {
+	ID     string
+	Center Vec
+	Shape  string
+	Color  color.Color
+	Size   float64
+	Alpha  float64
+	// Rotation is a clockwise angle in degrees.
+	Rotation float64
+	Blend    BlendMode
+	Hidden   bool
+	Layer    string
+	// Velocity and Acceleration are in canvas units per second and per
+	// second squared; the Loop moves the figure on every frame.
+	Velocity     Vec
+	Acceleration Vec
+	Boundary     Boundary
+}
+
+func NewFigure(id string, center Vec) Figure {
+	return Figure{
+		ID:     id,
+		Center: center,
+		Color:  DefaultFigureColor,
+		Size:   DefaultFigureSize,
+		Alpha:  1,
+		Layer:  DefaultLayer,
+	}
+}
+
+func (f Figure) shape() (Shape, error) {
+	if f.Shape == "" {
+		return ParseShape(DefaultShape)
+	}
+	return ParseShape(f.Shape)
+}
+
 type State struct {
 	BackgroundColor color.Color
-	BgRect          *image.Rectangle
-	Figures         []image.Point
+	// BackgroundGradient, when set, is painted instead of BackgroundColor.
+	BackgroundGradient *Gradient
+	BgRects            []BgRect
+	Figures            []Figure
+	Paths              []Path
+	Texts              []Text
+	Sprites            []Sprite
+	Layers             []Layer
+	CurrentLayer       string
 }
 
 func DefaultState() *State {
 	return &State{
 		BackgroundColor: color.Black,
-		Figures:         []image.Point{},
+		Figures:         []Figure{},
+		Layers:          []Layer{NewLayer(DefaultLayer)},
+		CurrentLayer:    DefaultLayer,
+	}
+}
+
+func (s *State) Clone() *State {
+	clone := *s
+	clone.BgRects = append([]BgRect(nil), s.BgRects...)
+	clone.Figures = append([]Figure{}, s.Figures...)
+	clone.Paths = append([]Path(nil), s.Paths...)
+	clone.Texts = append([]Text(nil), s.Texts...)
+	clone.Sprites = append([]Sprite(nil), s.Sprites...)
+	clone.Layers = append([]Layer{}, s.Layers...)
+	return &clone
+}
+
+func (s *State) Figure(id string) *Figure {
+	for i := range s.Figures {
+		if s.Figures[i].ID == id {
+			return &s.Figures[i]
+		}
+	}
+	return nil
+}
+
+func (s *State) bgRectIndex(id string) int {
+	for i := range s.BgRects {
+		if s.BgRects[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *State) newBgRectID() string {
+	for n := len(s.BgRects) + 1; ; n++ {
+		id := fmt.Sprintf("r%d", n)
+		if s.bgRectIndex(id) < 0 {
+			return id
+		}
+	}
+}
+
+func (s *State) newFigureID() string {
+	for n := len(s.Figures) + 1; ; n++ {
+		id := fmt.Sprintf("f%d", n)
+		if s.Figure(id) == nil {
+			return id
+		}
 	}
 }
 
@@ -25,6 +150,13 @@ type Operation interface {
 	Do(t screen.Texture, s *State) (needsUpdate bool)
 }
 
+// FallibleOp is an operation that can fail. The Loop runs it with Run and
+// reports the error through Execute, whereas Do only logs it.
+type FallibleOp interface {
+	Operation
+	Run(s *State) error
+}
+
 type OperationList []Operation
 
 func (ol OperationList) Do(t screen.Texture, s *State) (needsUpdate bool) {
@@ -55,6 +187,7 @@ type WhiteOp struct{}
 
 func (op WhiteOp) Do(t screen.Texture, s *State) bool {
 	s.BackgroundColor = color.White
+	s.BackgroundGradient = nil
 	return false
 }
 
@@ -62,88 +195,266 @@ type GreenOp struct{}
 
 func (op GreenOp) Do(t screen.Texture, s *State) bool {
 	s.BackgroundColor = color.RGBA{G: 0xff, A: 0xff}
+	s.BackgroundGradient = nil
+	return false
+}
+
+type FillOp struct {
+	Color color.Color
+}
+
+func (op FillOp) Do(t screen.Texture, s *State) bool {
+	s.BackgroundColor = op.Color
+	s.BackgroundGradient = nil
 	return false
 }
 
+// BgRectOp adds a background rectangle on top of the others, or replaces the
+// one with the same ID in place. A nil Color paints it black.
 type BgRectOp struct {
+	ID             string
 	X1, Y1, X2, Y2 float64
+	Color          color.Color
 }
 
 func (op BgRectOp) Do(t screen.Texture, s *State) bool {
-	size := t.Bounds().Size()
-	x1 := int(op.X1 * float64(size.X))
-	y1 := int(op.Y1 * float64(size.Y))
-	x2 := int(op.X2 * float64(size.X))
-	y2 := int(op.Y2 * float64(size.Y))
+	rect := BgRect{
+		ID:    op.ID,
+		Rect:  Rect{Min: Vec{X: op.X1, Y: op.Y1}, Max: Vec{X: op.X2, Y: op.Y2}},
+		Color: op.Color,
+		Layer: s.currentLayer(),
+	}
+	s.ensureLayer(rect.Layer)
+	if rect.ID == "" {
+		rect.ID = s.newBgRectID()
+	}
+	if rect.Color == nil {
+		rect.Color = color.Black
+	}
+
+	if i := s.bgRectIndex(rect.ID); i >= 0 {
+		s.BgRects[i] = rect
+	} else {
+		s.BgRects = append(s.BgRects, rect)
+	}
+	return false
+}
+
+type RectColorOp struct {
+	ID    string
+	Color color.Color
+}
+
+func (op RectColorOp) Do(t screen.Texture, s *State) bool {
+	if i := s.bgRectIndex(op.ID); i >= 0 {
+		s.BgRects[i].Color = op.Color
+		s.BgRects[i].Gradient = nil
+	}
+	return false
+}
+
+type RectRemoveOp struct {
+	ID string
+}
+
+func (op RectRemoveOp) Do(t screen.Texture, s *State) bool {
+	if i := s.bgRectIndex(op.ID); i >= 0 {
+		s.BgRects = append(s.BgRects[:i], s.BgRects[i+1:]...)
+	}
+	return false
+}
+
+// RaiseOp moves a background rectangle to the top of the stack.
+type RaiseOp struct {
+	ID string
+}
 
-	rect := image.Rect(x1, y1, x2, y2)
-	s.BgRect = &rect
+func (op RaiseOp) Do(t screen.Texture, s *State) bool {
+	if i := s.bgRectIndex(op.ID); i >= 0 {
+		rect := s.BgRects[i]
+		s.BgRects = append(append(s.BgRects[:i], s.BgRects[i+1:]...), rect)
+	}
 	return false
 }
 
+// LowerOp moves a background rectangle to the bottom of the stack.
+type LowerOp struct {
+	ID string
+}
+
+func (op LowerOp) Do(t screen.Texture, s *State) bool {
+	if i := s.bgRectIndex(op.ID); i >= 0 {
+		rect := s.BgRects[i]
+		copy(s.BgRects[1:i+1], s.BgRects[:i])
+		s.BgRects[0] = rect
+	}
+	return false
+}
+
+// FigureOp adds a figure or replaces the one with the same ID. Zero Color and
+// Size and a nil Alpha select the defaults.
 type FigureOp struct {
-	X, Y float64
+	ID    string
+	X, Y  float64
+	Shape string
+	Color color.Color
+	Size  float64
+	Alpha *float64
 }
 
 func (op FigureOp) Do(t screen.Texture, s *State) bool {
-	size := t.Bounds().Size()
-	x := int(op.X * float64(size.X))
-	y := int(op.Y * float64(size.Y))
-	s.Figures = append(s.Figures, image.Point{X: x, Y: y})
+	id := op.ID
+	if id == "" {
+		id = s.newFigureID()
+	}
+	figure := NewFigure(id, Vec{X: op.X, Y: op.Y})
+	figure.Shape = op.Shape
+	figure.Layer = s.currentLayer()
+	s.ensureLayer(figure.Layer)
+	if op.Color != nil {
+		figure.Color = op.Color
+	}
+	if op.Size > 0 {
+		figure.Size = op.Size
+	}
+	if op.Alpha != nil {
+		figure.Alpha = *op.Alpha
+	}
+
+	if f := s.Figure(id); f != nil {
+		figure.Hidden = f.Hidden
+		*f = figure
+		return false
+	}
+	s.Figures = append(s.Figures, figure)
 	return false
 }
 
+// MoveOp shifts the figure with the given ID, or every figure when ID is empty.
 type MoveOp struct {
+	ID   string
 	X, Y float64
 }
 
 func (op MoveOp) Do(t screen.Texture, s *State) bool {
-	size := t.Bounds().Size()
-	dx := int(op.X * float64(size.X))
-	dy := int(op.Y * float64(size.Y))
+	for i := range s.Figures {
+		if op.ID == "" || s.Figures[i].ID == op.ID {
+			s.Figures[i].Center.X += op.X
+			s.Figures[i].Center.Y += op.Y
+		}
+	}
+	return false
+}
+
+type MoveToOp struct {
+	ID   string
+	X, Y float64
+}
+
+func (op MoveToOp) Do(t screen.Texture, s *State) bool {
+	if f := s.Figure(op.ID); f != nil {
+		f.Center = Vec{X: op.X, Y: op.Y}
+	}
+	return false
+}
+
+type RemoveOp struct {
+	ID string
+}
 
+func (op RemoveOp) Do(t screen.Texture, s *State) bool {
 	for i := range s.Figures {
-		s.Figures[i].X += dx
-		s.Figures[i].Y += dy
+		if s.Figures[i].ID == op.ID {
+			s.Figures = append(s.Figures[:i], s.Figures[i+1:]...)
+			break
+		}
 	}
+	// Labels attached to the figure go away with it.
+	texts := s.Texts[:0]
+	for _, text := range s.Texts {
+		if text.Figure != op.ID {
+			texts = append(texts, text)
+		}
+	}
+	s.Texts = texts
 	return false
 }
 
-type ResetOp struct{}
+type HideOp struct {
+	ID string
+}
 
-func (op ResetOp) Do(t screen.Texture, s *State) bool {
-	s.BackgroundColor = color.Black
-	s.BgRect = nil
-	s.Figures = []image.Point{}
+func (op HideOp) Do(t screen.Texture, s *State) bool {
+	if f := s.Figure(op.ID); f != nil {
+		f.Hidden = true
+	}
 	return false
 }
 
-type DrawStateOp struct{}
+type ShowOp struct {
+	ID string
+}
 
-func (op DrawStateOp) Do(t screen.Texture, s *State) bool {
-	bounds := t.Bounds()
-	t.Fill(bounds, s.BackgroundColor, draw.Src)
+func (op ShowOp) Do(t screen.Texture, s *State) bool {
+	if f := s.Figure(op.ID); f != nil {
+		f.Hidden = false
+	}
+	return false
+}
+
+type ColorOp struct {
+	ID    string
+	Color color.Color
+}
 
-	if s.BgRect != nil {
-		t.Fill(*s.BgRect, color.Black, draw.Src)
+func (op ColorOp) Do(t screen.Texture, s *State) bool {
+	if f := s.Figure(op.ID); f != nil {
+		f.Color = op.Color
 	}
+	return false
+}
 
-	figureColor := color.RGBA{R: 0xff, G: 0xff, B: 0x00, A: 0xff}
-	figureSize := 200
+type SizeOp struct {
+	ID   string
+	Size float64
+}
 
-	for _, center := range s.Figures {
-		halfFigureSize := figureSize / 2
-		horizontalRect := image.Rectangle{
-			Min: image.Point{X: center.X - halfFigureSize, Y: center.Y - halfFigureSize/3},
-			Max: image.Point{X: center.X + halfFigureSize, Y: center.Y + halfFigureSize/3},
-		}
-		verticalRect := image.Rectangle{
-			Min: image.Point{X: center.X - halfFigureSize/3, Y: center.Y - halfFigureSize},
-			Max: image.Point{X: center.X + halfFigureSize/3, Y: center.Y + halfFigureSize},
-		}
-		t.Fill(horizontalRect, figureColor, draw.Src)
-		t.Fill(verticalRect, figureColor, draw.Src)
+func (op SizeOp) Do(t screen.Texture, s *State) bool {
+	if f := s.Figure(op.ID); f != nil {
+		f.Size = op.Size
 	}
+	return false
+}
 
+type AlphaOp struct {
+	ID    string
+	Alpha float64
+}
+
+func (op AlphaOp) Do(t screen.Texture, s *State) bool {
+	if f := s.Figure(op.ID); f != nil {
+		f.Alpha = op.Alpha
+	}
+	return false
+}
+
+type ResetOp struct{}
+
+func (op ResetOp) Do(t screen.Texture, s *State) bool {
+	*s = *DefaultState()
+	return false
+}
+
+// DrawStateOp renders the state into Buffer and uploads it to the texture.
+// Buffer has to be of the same size as the texture, and Assets provides the
+// images of sprites.
+type DrawStateOp struct {
+	Buffer screen.Buffer
+	Assets *AssetStore
+}
+
+func (op DrawStateOp) Do(t screen.Texture, s *State) bool {
+	Render(op.Buffer.RGBA(), s, op.Assets)
+	t.Upload(image.Point{}, op.Buffer, op.Buffer.Bounds())
 	return false
 }
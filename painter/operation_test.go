@@ -4,6 +4,7 @@ import (
 	"image"
 	"image/color"
 	"image/draw"
+	"math"
 	"reflect"
 	"testing"
 
@@ -22,8 +23,25 @@ type mockTexture struct {
 	size   image.Point
 	buffer *image.RGBA
 	fillCalls []fillCall
+	uploads   int
 }
 
+type mockBuffer struct {
+	rgba *image.RGBA
+}
+
+func newMockBuffer(size image.Point) *mockBuffer {
+	return &mockBuffer{rgba: image.NewRGBA(image.Rectangle{Max: size})}
+}
+
+func (m *mockBuffer) Release() {}
+
+func (m *mockBuffer) Size() image.Point { return m.rgba.Bounds().Size() }
+
+func (m *mockBuffer) Bounds() image.Rectangle { return m.rgba.Bounds() }
+
+func (m *mockBuffer) RGBA() *image.RGBA { return m.rgba }
+
 var testTextureSize = image.Pt(800, 800)
 
 func (m *mockTexture) Release() {}
@@ -34,7 +52,10 @@ func (m *mockTexture) Bounds() image.Rectangle {
 	return image.Rectangle{Max: m.size}
 }
 
-func (m *mockTexture) Upload(dp image.Point, src screen.Buffer, sr image.Rectangle) {}
+func (m *mockTexture) Upload(dp image.Point, src screen.Buffer, sr image.Rectangle) {
+	draw.Draw(m.buffer, sr.Sub(sr.Min).Add(dp), src.RGBA(), sr.Min, draw.Src)
+	m.uploads++
+}
 
 func (m *mockTexture) Fill(dr image.Rectangle, src color.Color, op draw.Op) {
 	draw.Draw(m.buffer, dr, image.NewUniform(src), image.Point{}, op)
@@ -63,16 +84,31 @@ func checkPixelColor(t *testing.T, texture *mockTexture, x, y int, expected colo
 	}
 }
 
+func newFigure(id string, x, y float64) painter.Figure {
+	return painter.NewFigure(id, painter.Vec{X: x, Y: y})
+}
+
+func hiddenFigure(id string, x, y float64) painter.Figure {
+	f := newFigure(id, x, y)
+	f.Hidden = true
+	return f
+}
+
+func bgRect(id string, x1, y1, x2, y2 float64, c color.Color) painter.BgRect {
+	return painter.BgRect{
+		ID:    id,
+		Rect:  painter.Rect{Min: painter.Vec{X: x1, Y: y1}, Max: painter.Vec{X: x2, Y: y2}},
+		Color: c,
+		Layer: painter.DefaultLayer,
+	}
+}
+
 func checkState(t *testing.T, state *painter.State, expected painter.State, message string) {
 	if state.BackgroundColor != expected.BackgroundColor {
 		t.Errorf("%s: BackgroundColor mismatch. Expected: %v, Got: %v", message, expected.BackgroundColor, state.BackgroundColor)
 	}
-	if (state.BgRect == nil && expected.BgRect != nil) || (state.BgRect != nil && expected.BgRect == nil) {
-		 t.Errorf("%s: BgRect mismatch. Expected: %v, Got: %v", message, expected.BgRect, state.BgRect)
-	} else if state.BgRect != nil && expected.BgRect != nil {
-		if *state.BgRect != *expected.BgRect {
-			 t.Errorf("%s: BgRect value mismatch. Expected: %v, Got: %v", message, *expected.BgRect, *state.BgRect)
-		}
+	if (len(state.BgRects) != 0 || len(expected.BgRects) != 0) && !reflect.DeepEqual(state.BgRects, expected.BgRects) {
+		t.Errorf("%s: BgRects mismatch. Expected: %v, Got: %v", message, expected.BgRects, state.BgRects)
 	}
 	if !reflect.DeepEqual(state.Figures, expected.Figures) {
 		t.Errorf("%s: Figures mismatch. Expected: %v, Got: %v", message, expected.Figures, state.Figures)
@@ -89,7 +125,7 @@ func TestOperations_Do(t *testing.T) {
 		op := painter.WhiteOp{}
 		needsUpdate := op.Do(texture, state)
 
-		checkState(t, state, painter.State{BackgroundColor: color.White, BgRect: nil, Figures: []image.Point{}}, "State after WhiteOp")
+		checkState(t, state, painter.State{BackgroundColor: color.White, Figures: []painter.Figure{}}, "State after WhiteOp")
 		if needsUpdate {
 			t.Error("WhiteOp returned needsUpdate = true unexpectedly")
 		}
@@ -106,7 +142,7 @@ func TestOperations_Do(t *testing.T) {
 		needsUpdate := op.Do(texture, state)
 
 		greenColor := color.RGBA{G: 255, A: 255}
-		checkState(t, state, painter.State{BackgroundColor: greenColor, BgRect: nil, Figures: []image.Point{}}, "State after GreenOp")
+		checkState(t, state, painter.State{BackgroundColor: greenColor, Figures: []painter.Figure{}}, "State after GreenOp")
 		if needsUpdate {
 			t.Error("GreenOp returned needsUpdate = true unexpectedly")
 		}
@@ -115,6 +151,23 @@ func TestOperations_Do(t *testing.T) {
 		}
 	})
 
+	t.Run("FillOp", func(t *testing.T) {
+		state := painter.DefaultState()
+		texture := newMockTexture(testTextureSize)
+
+		brandColor := color.NRGBA{R: 0x12, G: 0x34, B: 0x56, A: 0xff}
+		op := painter.FillOp{Color: brandColor}
+		needsUpdate := op.Do(texture, state)
+
+		checkState(t, state, painter.State{BackgroundColor: brandColor, Figures: []painter.Figure{}}, "State after FillOp")
+		if needsUpdate {
+			t.Error("FillOp returned needsUpdate = true unexpectedly")
+		}
+		if len(texture.fillCalls) != 0 {
+			t.Errorf("FillOp called Fill %d times, expected 0", len(texture.fillCalls))
+		}
+	})
+
 	t.Run("BgRectOp", func(t *testing.T) {
 		state := painter.DefaultState()
 		texture := newMockTexture(testTextureSize)
@@ -122,8 +175,8 @@ func TestOperations_Do(t *testing.T) {
 		op := painter.BgRectOp{X1: 0.1, Y1: 0.1, X2: 0.9, Y2: 0.9}
 		needsUpdate := op.Do(texture, state)
 
-		expectedRect := image.Rect(int(0.1*800), int(0.1*800), int(0.9*800), int(0.9*800))
-		checkState(t, state, painter.State{BackgroundColor: color.Black, BgRect: &expectedRect, Figures: []image.Point{}}, "State after BgRectOp")
+		expectedRect := bgRect("r1", 0.1, 0.1, 0.9, 0.9, color.Black)
+		checkState(t, state, painter.State{BackgroundColor: color.Black, BgRects: []painter.BgRect{expectedRect}, Figures: []painter.Figure{}}, "State after BgRectOp")
 		if needsUpdate {
 			t.Error("BgRectOp returned needsUpdate = true unexpectedly")
 		}
@@ -131,10 +184,42 @@ func TestOperations_Do(t *testing.T) {
 			t.Errorf("BgRectOp called Fill %d times, expected 0", len(texture.fillCalls))
 		}
 
-		op2 := painter.BgRectOp{X1: 0.2, Y1: 0.2, X2: 0.8, Y2: 0.8}
+		red := color.NRGBA{R: 255, A: 255}
+		op2 := painter.BgRectOp{X1: 0.2, Y1: 0.2, X2: 0.8, Y2: 0.8, Color: red}
 		op2.Do(texture, state)
-		expectedRect2 := image.Rect(int(0.2*800), int(0.2*800), int(0.8*800), int(0.8*800))
-		checkState(t, state, painter.State{BackgroundColor: color.Black, BgRect: &expectedRect2, Figures: []image.Point{}}, "State after second BgRectOp")
+		expectedRect2 := bgRect("r2", 0.2, 0.2, 0.8, 0.8, red)
+		checkState(t, state, painter.State{BackgroundColor: color.Black, BgRects: []painter.BgRect{expectedRect, expectedRect2}, Figures: []painter.Figure{}}, "State after second BgRectOp")
+
+		painter.BgRectOp{ID: "r1", X1: 0, Y1: 0, X2: 0.5, Y2: 0.5}.Do(texture, state)
+		expectedRect = bgRect("r1", 0, 0, 0.5, 0.5, color.Black)
+		checkState(t, state, painter.State{BackgroundColor: color.Black, BgRects: []painter.BgRect{expectedRect, expectedRect2}, Figures: []painter.Figure{}}, "State after replacing a BgRect")
+	})
+
+	t.Run("RectOps", func(t *testing.T) {
+		state := painter.DefaultState()
+		texture := newMockTexture(testTextureSize)
+		blue := color.NRGBA{B: 255, A: 255}
+		a := bgRect("a", 0, 0, 0.5, 0.5, color.Black)
+		b := bgRect("b", 0.25, 0.25, 0.75, 0.75, color.Black)
+		c := bgRect("c", 0.5, 0.5, 1, 1, color.Black)
+		state.BgRects = []painter.BgRect{a, b, c}
+
+		painter.RaiseOp{ID: "a"}.Do(texture, state)
+		checkState(t, state, painter.State{BackgroundColor: color.Black, BgRects: []painter.BgRect{b, c, a}, Figures: []painter.Figure{}}, "State after RaiseOp")
+
+		painter.LowerOp{ID: "c"}.Do(texture, state)
+		checkState(t, state, painter.State{BackgroundColor: color.Black, BgRects: []painter.BgRect{c, b, a}, Figures: []painter.Figure{}}, "State after LowerOp")
+
+		painter.RectColorOp{ID: "b", Color: blue}.Do(texture, state)
+		painter.RectRemoveOp{ID: "c"}.Do(texture, state)
+		b.Color = blue
+		checkState(t, state, painter.State{BackgroundColor: color.Black, BgRects: []painter.BgRect{b, a}, Figures: []painter.Figure{}}, "State after RectColorOp and RectRemoveOp")
+
+		state.BackgroundColor = color.White
+		painter.DrawStateOp{Buffer: newMockBuffer(texture.size)}.Do(texture, state)
+		checkPixelColor(t, texture, 300, 300, color.Black, "Top rect is not painted over the lower one")
+		checkPixelColor(t, texture, 500, 500, blue, "Recolored rect has wrong color")
+		checkPixelColor(t, texture, 700, 700, color.White, "Removed rect is still painted over the background")
 	})
 
 	t.Run("FigureOp", func(t *testing.T) {
@@ -144,8 +229,8 @@ func TestOperations_Do(t *testing.T) {
 		op1 := painter.FigureOp{X: 0.5, Y: 0.5}
 		needsUpdate1 := op1.Do(texture, state)
 
-		expectedFigures1 := []image.Point{{X: int(0.5*800), Y: int(0.5*800)}}
-		checkState(t, state, painter.State{BackgroundColor: color.Black, BgRect: nil, Figures: expectedFigures1}, "State after first FigureOp")
+		expectedFigures1 := []painter.Figure{newFigure("f1", 0.5, 0.5)}
+		checkState(t, state, painter.State{BackgroundColor: color.Black, Figures: expectedFigures1}, "State after first FigureOp")
 		if needsUpdate1 {
 			t.Error("FigureOp returned needsUpdate = true unexpectedly")
 		}
@@ -156,8 +241,11 @@ func TestOperations_Do(t *testing.T) {
 		op2 := painter.FigureOp{X: 0.2, Y: 0.8}
 		needsUpdate2 := op2.Do(texture, state)
 
-		expectedFigures2 := []image.Point{{X: int(0.5*800), Y: int(0.5*800)}, {X: int(0.2*800), Y: int(0.8*800)}}
-		checkState(t, state, painter.State{BackgroundColor: color.Black, BgRect: nil, Figures: expectedFigures2}, "State after second FigureOp")
+		expectedFigures2 := []painter.Figure{
+			newFigure("f1", 0.5, 0.5),
+			newFigure("f2", 0.2, 0.8),
+		}
+		checkState(t, state, painter.State{BackgroundColor: color.Black, Figures: expectedFigures2}, "State after second FigureOp")
 		if needsUpdate2 {
 			t.Error("Second FigureOp returned needsUpdate = true unexpectedly")
 		}
@@ -168,14 +256,14 @@ func TestOperations_Do(t *testing.T) {
 
 	t.Run("MoveOp", func(t *testing.T) {
 		state := painter.DefaultState()
-		state.Figures = []image.Point{{X: 400, Y: 400}, {X: 100, Y: 100}}
+		state.Figures = []painter.Figure{newFigure("a", 0.5, 0.5), newFigure("b", 0.125, 0.125)}
 		texture := newMockTexture(testTextureSize)
 
-		op := painter.MoveOp{X: 0.1, Y: 0.1}
+		op := painter.MoveOp{X: 0.125, Y: 0.25}
 		needsUpdate := op.Do(texture, state)
 
-		expectedFigures := []image.Point{{X: 480, Y: 480}, {X: 180, Y: 180}}
-		checkState(t, state, painter.State{BackgroundColor: color.Black, BgRect: nil, Figures: expectedFigures}, "State after MoveOp")
+		expectedFigures := []painter.Figure{newFigure("a", 0.625, 0.75), newFigure("b", 0.25, 0.375)}
+		checkState(t, state, painter.State{BackgroundColor: color.Black, Figures: expectedFigures}, "State after MoveOp")
 		if needsUpdate {
 			t.Error("MoveOp returned needsUpdate = true unexpectedly")
 		}
@@ -184,18 +272,83 @@ func TestOperations_Do(t *testing.T) {
 		}
 	})
 
+	t.Run("FigureOpWithExistingID", func(t *testing.T) {
+		state := painter.DefaultState()
+		texture := newMockTexture(testTextureSize)
+
+		painter.FigureOp{ID: "a", X: 0.5, Y: 0.5}.Do(texture, state)
+		painter.FigureOp{ID: "a", X: 0.25, Y: 0.25}.Do(texture, state)
+
+		expectedFigures := []painter.Figure{newFigure("a", 0.25, 0.25)}
+		checkState(t, state, painter.State{BackgroundColor: color.Black, Figures: expectedFigures}, "State after repeated FigureOp")
+	})
+
+	t.Run("PerFigureOps", func(t *testing.T) {
+		state := painter.DefaultState()
+		state.Figures = []painter.Figure{newFigure("a", 0.5, 0.5), newFigure("b", 0.125, 0.125)}
+		texture := newMockTexture(testTextureSize)
+
+		opList := painter.OperationList{
+			painter.MoveOp{ID: "b", X: 0.125, Y: 0},
+			painter.MoveToOp{ID: "a", X: 0.75, Y: 0.5},
+			painter.HideOp{ID: "a"},
+			painter.MoveOp{ID: "missing", X: 0.1, Y: 0.1},
+		}
+		if opList.Do(texture, state) {
+			t.Error("Per-figure operations returned needsUpdate = true unexpectedly")
+		}
+
+		expectedFigures := []painter.Figure{hiddenFigure("a", 0.75, 0.5), newFigure("b", 0.25, 0.125)}
+		checkState(t, state, painter.State{BackgroundColor: color.Black, Figures: expectedFigures}, "State after per-figure ops")
+
+		painter.ShowOp{ID: "a"}.Do(texture, state)
+		painter.RemoveOp{ID: "b"}.Do(texture, state)
+
+		expectedFigures = []painter.Figure{newFigure("a", 0.75, 0.5)}
+		checkState(t, state, painter.State{BackgroundColor: color.Black, Figures: expectedFigures}, "State after ShowOp and RemoveOp")
+	})
+
+	t.Run("StyleOps", func(t *testing.T) {
+		state := painter.DefaultState()
+		texture := newMockTexture(testTextureSize)
+
+		red := color.NRGBA{R: 255, A: 255}
+		alpha := 0.5
+		painter.FigureOp{ID: "a", X: 0.5, Y: 0.5, Shape: "circle", Color: red, Size: 0.1, Alpha: &alpha}.Do(texture, state)
+
+		expected := newFigure("a", 0.5, 0.5)
+		expected.Shape, expected.Color, expected.Size, expected.Alpha = "circle", red, 0.1, 0.5
+		checkState(t, state, painter.State{BackgroundColor: color.Black, Figures: []painter.Figure{expected}}, "State after styled FigureOp")
+
+		blue := color.NRGBA{B: 255, A: 255}
+		painter.OperationList{
+			painter.ColorOp{ID: "a", Color: blue},
+			painter.SizeOp{ID: "a", Size: 0.3},
+			painter.AlphaOp{ID: "a", Alpha: 0},
+		}.Do(texture, state)
+
+		expected.Color, expected.Size, expected.Alpha = blue, 0.3, 0
+		checkState(t, state, painter.State{BackgroundColor: color.Black, Figures: []painter.Figure{expected}}, "State after style ops")
+
+		transparent := 0.0
+		painter.FigureOp{ID: "a", X: 0.5, Y: 0.5, Alpha: &transparent}.Do(texture, state)
+		expected = newFigure("a", 0.5, 0.5)
+		expected.Alpha = 0
+		checkState(t, state, painter.State{BackgroundColor: color.Black, Figures: []painter.Figure{expected}}, "State after transparent FigureOp")
+	})
+
 	t.Run("ResetOp", func(t *testing.T) {
 		state := &painter.State{
 			BackgroundColor: color.RGBA{G: 255, A: 255},
-			BgRect:          &image.Rectangle{Min: image.Point{100, 100}, Max: image.Point{700, 700}},
-			Figures:         []image.Point{{200, 200}, {600, 600}},
+			BgRects:         []painter.BgRect{bgRect("r1", 0.125, 0.125, 0.875, 0.875, color.Black)},
+			Figures:         []painter.Figure{newFigure("a", 0.25, 0.25), newFigure("b", 0.75, 0.75)},
 		}
 		texture := newMockTexture(testTextureSize)
 
 		op := painter.ResetOp{}
 		needsUpdate := op.Do(texture, state)
 
-		checkState(t, state, painter.State{BackgroundColor: color.Black, BgRect: nil, Figures: []image.Point{}}, "State after ResetOp")
+		checkState(t, state, painter.State{BackgroundColor: color.Black, Figures: []painter.Figure{}}, "State after ResetOp")
 		if needsUpdate {
 			t.Error("ResetOp returned needsUpdate = true unexpectedly")
 		}
@@ -211,7 +364,7 @@ func TestOperations_Do(t *testing.T) {
 		op := painter.UpdateOp
 		needsUpdate := op.Do(texture, state)
 
-		checkState(t, state, painter.State{BackgroundColor: color.Black, BgRect: nil, Figures: []image.Point{}}, "State after UpdateOp")
+		checkState(t, state, painter.State{BackgroundColor: color.Black, Figures: []painter.Figure{}}, "State after UpdateOp")
 		if len(texture.fillCalls) != 0 {
 			t.Errorf("UpdateOp called Fill %d times, expected 0", len(texture.fillCalls))
 		}
@@ -225,11 +378,11 @@ func TestOperations_Do(t *testing.T) {
 		texture := newMockTexture(testTextureSize)
 		state := &painter.State{
 			BackgroundColor: color.RGBA{G: 255, A: 255},
-			BgRect:          &image.Rectangle{Min: image.Point{200, 200}, Max: image.Point{600, 600}},
-			Figures:         []image.Point{{400, 400}, {100, 100}},
+			BgRects:         []painter.BgRect{bgRect("r1", 0.25, 0.25, 0.75, 0.75, color.Black)},
+			Figures:         []painter.Figure{newFigure("a", 0.5, 0.5), newFigure("b", 0.125, 0.125), hiddenFigure("c", 0.875, 0.875)},
 		}
 
-		op := painter.DrawStateOp{}
+		op := painter.DrawStateOp{Buffer: newMockBuffer(texture.size)}
 		needsUpdate := op.Do(texture, state)
 
 		checkState(t, state, *state, "State after DrawStateOp")
@@ -241,11 +394,437 @@ func TestOperations_Do(t *testing.T) {
 		checkPixelColor(t, texture, 300, 300, color.Black, "Pixel color mismatch in DrawStateOp (BgRect)")
 		checkPixelColor(t, texture, 400, 400, color.RGBA{R: 255, G: 255, B: 0, A: 255}, "Pixel color mismatch in DrawStateOp (Figure 1)")
 		checkPixelColor(t, texture, 100, 100, color.RGBA{R: 255, G: 255, B: 0, A: 255}, "Pixel color mismatch in DrawStateOp (Figure 2)")
+		checkPixelColor(t, texture, 700, 700, color.RGBA{G: 255, A: 255}, "Hidden figure was drawn by DrawStateOp")
+
+		if len(texture.fillCalls) != 0 || texture.uploads != 1 {
+			t.Errorf("DrawStateOp called Fill %d times and Upload %d times, expected a single upload", len(texture.fillCalls), texture.uploads)
+		}
+	})
+
+	t.Run("DrawStateOpWithStyledFigures", func(t *testing.T) {
+		texture := newMockTexture(testTextureSize)
+		small := newFigure("small", 0.125, 0.125)
+		small.Color, small.Size = color.RGBA{R: 255, A: 255}, 0.05
+		translucent := newFigure("translucent", 0.5, 0.5)
+		translucent.Color, translucent.Alpha = color.White, 0.5
+		state := &painter.State{
+			BackgroundColor: color.Black,
+			Figures:         []painter.Figure{small, translucent},
+		}
+
+		painter.DrawStateOp{Buffer: newMockBuffer(texture.size)}.Do(texture, state)
+
+		checkPixelColor(t, texture, 100, 100, color.RGBA{R: 255, A: 255}, "Small figure center has wrong color")
+		checkPixelColor(t, texture, 100, 125, color.Black, "Small figure is larger than its size")
+		checkPixelColor(t, texture, 400, 400, color.RGBA{R: 128, G: 128, B: 128, A: 255}, "Translucent cross center is not blended once")
+		checkPixelColor(t, texture, 480, 400, color.RGBA{R: 128, G: 128, B: 128, A: 255}, "Translucent cross arm is not blended over background")
+	})
+
+	t.Run("DrawStateOpAtDifferentSizes", func(t *testing.T) {
+		state := &painter.State{
+			BackgroundColor: color.White,
+			BgRects:         []painter.BgRect{bgRect("r1", 0, 0, 0.5, 0.5, color.Black)},
+			Figures:         []painter.Figure{newFigure("a", 0.75, 0.75)},
+		}
+		figureColor := color.RGBA{R: 255, G: 255, B: 0, A: 255}
+
+		for _, size := range []image.Point{image.Pt(400, 400), image.Pt(1600, 1200)} {
+			texture := newMockTexture(size)
+			painter.DrawStateOp{Buffer: newMockBuffer(texture.size)}.Do(texture, state)
+
+			checkPixelColor(t, texture, size.X/2-1, size.Y/2-1, color.Black, "BgRect does not scale with the texture")
+			checkPixelColor(t, texture, size.X/2+1, size.Y/2+1, color.White, "BgRect does not scale with the texture")
+			checkPixelColor(t, texture, size.X*3/4, size.Y*3/4, figureColor, "Figure does not follow the texture size")
+			unit := min(size.X, size.Y)
+			checkPixelColor(t, texture, size.X*3/4+unit/8-2, size.Y*3/4, figureColor, "Figure size does not scale with the texture")
+			checkPixelColor(t, texture, size.X*3/4+unit/8+2, size.Y*3/4, color.White, "Figure size does not scale with the texture")
+		}
+	})
+
+	t.Run("TransformOps", func(t *testing.T) {
+		state := &painter.State{Figures: []painter.Figure{newFigure("a", 0.75, 0.5), newFigure("b", 0.25, 0.5)}}
+		texture := newMockTexture(testTextureSize)
+
+		painter.RotateOp{IDs: []string{"a"}, Degrees: 90, Pivot: &painter.Vec{X: 0.5, Y: 0.5}}.Do(texture, state)
+		painter.RotateOp{Degrees: 45}.Do(texture, state)
+		painter.ScaleOp{IDs: []string{"b"}, Factor: 2, Pivot: &painter.Vec{X: 0.5, Y: 0.5}}.Do(texture, state)
+		painter.ScaleOp{Factor: 0.5}.Do(texture, state)
+
+		a, b := state.Figure("a"), state.Figure("b")
+		if math.Abs(a.Center.X-0.5) > 1e-9 || math.Abs(a.Center.Y-0.75) > 1e-9 || a.Rotation != 135 || a.Size != 0.125 {
+			t.Errorf("RotateOp around a pivot produced %+v", *a)
+		}
+		if b.Center != (painter.Vec{X: 0, Y: 0.5}) || b.Rotation != 45 || b.Size != 0.25 {
+			t.Errorf("ScaleOp around a pivot produced %+v", *b)
+		}
+	})
+
+	t.Run("DrawStateOpWithRotatedFigure", func(t *testing.T) {
+		texture := newMockTexture(testTextureSize)
+		rotated := newFigure("a", 0.5, 0.5)
+		rotated.Rotation = 45
+		state := &painter.State{BackgroundColor: color.Black, Figures: []painter.Figure{rotated}}
+
+		painter.DrawStateOp{Buffer: newMockBuffer(texture.size)}.Do(texture, state)
+
+		figureColor := color.RGBA{R: 255, G: 255, B: 0, A: 255}
+		checkPixelColor(t, texture, 450, 450, figureColor, "Rotated cross arm is missing on the diagonal")
+		checkPixelColor(t, texture, 460, 400, color.Black, "Cross arm was drawn without rotation")
+	})
+
+	t.Run("PathOps", func(t *testing.T) {
+		state := painter.DefaultState()
+		texture := newMockTexture(testTextureSize)
+		points := []painter.Vec{{X: 0, Y: 0}, {X: 1, Y: 1}}
+
+		painter.PathOp{Kind: painter.PathLine, Points: points}.Do(texture, state)
+		painter.PathOp{ID: "b", Kind: painter.PathLine, Points: points}.Do(texture, state)
+		painter.PathOp{ID: "b", Kind: painter.PathLine, Points: points, Stroke: painter.Stroke{Width: 0.5}}.Do(texture, state)
+		painter.PathRemoveOp{ID: "p1"}.Do(texture, state)
+
+		expected := []painter.Path{{
+			ID:     "b",
+			Kind:   painter.PathLine,
+			Points: points,
+			Stroke: painter.Stroke{Width: 0.5, Color: painter.DefaultStrokeColor, Cap: painter.CapButt, Join: painter.JoinMiter},
+			Layer:  painter.DefaultLayer,
+		}}
+		if !reflect.DeepEqual(state.Paths, expected) {
+			t.Errorf("Paths mismatch. Expected: %v, Got: %v", expected, state.Paths)
+		}
+	})
+
+	t.Run("DrawStateOpWithPaths", func(t *testing.T) {
+		texture := newMockTexture(testTextureSize)
+		line := func(y float64, stroke painter.Stroke) painter.Path {
+			stroke.Color = color.White
+			return painter.Path{Kind: painter.PathLine, Points: []painter.Vec{{X: 0.25, Y: y}, {X: 0.75, Y: y}}, Stroke: stroke}
+		}
+		state := &painter.State{
+			BackgroundColor: color.Black,
+			Paths: []painter.Path{
+				line(0.125, painter.Stroke{Width: 0.05, Cap: painter.CapButt}),
+				line(0.375, painter.Stroke{Width: 0.05, Cap: painter.CapRound}),
+				line(0.625, painter.Stroke{Width: 0.05, Cap: painter.CapSquare}),
+				line(0.875, painter.Stroke{Width: 0.05, Dash: []float64{0.125}}),
+			},
+		}
+
+		painter.DrawStateOp{Buffer: newMockBuffer(texture.size)}.Do(texture, state)
+
+		checkPixelColor(t, texture, 400, 100, color.White, "Line is not stroked")
+		checkPixelColor(t, texture, 400, 125, color.Black, "Line is wider than its stroke width")
+		checkPixelColor(t, texture, 190, 100, color.Black, "Butt cap extends past the end point")
+		checkPixelColor(t, texture, 190, 300, color.White, "Round cap is missing")
+		checkPixelColor(t, texture, 183, 283, color.Black, "Round cap has square corners")
+		checkPixelColor(t, texture, 183, 483, color.White, "Square cap is missing")
+		checkPixelColor(t, texture, 250, 700, color.White, "First dash is missing")
+		checkPixelColor(t, texture, 350, 700, color.Black, "Dash gap is filled")
+		checkPixelColor(t, texture, 450, 700, color.White, "Second dash is missing")
+	})
+
+	t.Run("DrawStateOpWithJoins", func(t *testing.T) {
+		corner := []painter.Vec{{X: 0.25, Y: 0.75}, {X: 0.5, Y: 0.25}, {X: 0.75, Y: 0.75}}
+		tip := func(join painter.LineJoin) color.Color {
+			texture := newMockTexture(testTextureSize)
+			state := &painter.State{
+				BackgroundColor: color.Black,
+				Paths: []painter.Path{{
+					Kind:   painter.PathPolyline,
+					Points: corner,
+					Stroke: painter.Stroke{Width: 0.05, Color: color.White, Join: join},
+				}},
+			}
+			painter.DrawStateOp{Buffer: newMockBuffer(texture.size)}.Do(texture, state)
+			return texture.buffer.At(400, 180)
+		}
+
+		if c := tip(painter.JoinMiter); c != (color.RGBA{R: 255, G: 255, B: 255, A: 255}) {
+			t.Errorf("Miter join does not reach the corner tip, got %v", c)
+		}
+		if c := tip(painter.JoinBevel); c != (color.RGBA{A: 255}) {
+			t.Errorf("Bevel join reaches the miter tip, got %v", c)
+		}
+	})
 
-		expectedFillCalls := 1 + 1 + (2 * 2)
-		if len(texture.fillCalls) != expectedFillCalls {
-			 t.Errorf("DrawStateOp called Fill %d times, expected %d", len(texture.fillCalls), expectedFillCalls)
+	t.Run("TextOps", func(t *testing.T) {
+		state := painter.DefaultState()
+		texture := newMockTexture(testTextureSize)
+
+		painter.FigureOp{ID: "a", X: 0.5, Y: 0.5}.Do(texture, state)
+		painter.TextOp{Content: "title", X: 0.5, Y: 0.1}.Do(texture, state)
+		painter.TextOp{ID: "label", Content: "a", Y: 0.2, Figure: "a", Align: painter.AlignCenter}.Do(texture, state)
+
+		expected := []painter.Text{
+			{ID: "t1", Content: "title", Position: painter.Vec{X: 0.5, Y: 0.1}, Size: painter.DefaultTextSize, Color: painter.DefaultTextColor, Align: painter.AlignLeft, Layer: painter.DefaultLayer},
+			{ID: "label", Content: "a", Position: painter.Vec{Y: 0.2}, Size: painter.DefaultTextSize, Color: painter.DefaultTextColor, Align: painter.AlignCenter, Figure: "a", Layer: painter.DefaultLayer},
+		}
+		if !reflect.DeepEqual(state.Texts, expected) {
+			t.Errorf("Texts mismatch. Expected: %v, Got: %v", expected, state.Texts)
+		}
+
+		painter.RemoveOp{ID: "a"}.Do(texture, state)
+		painter.TextRemoveOp{ID: "t1"}.Do(texture, state)
+		if len(state.Texts) != 0 {
+			t.Errorf("Texts were not removed along with their figure: %v", state.Texts)
+		}
+	})
+
+	t.Run("DrawStateOpWithText", func(t *testing.T) {
+		inked := func(texture *mockTexture, r image.Rectangle) int {
+			n := 0
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					if texture.buffer.RGBAAt(x, y) != (color.RGBA{A: 255}) {
+						n++
+					}
+				}
+			}
+			return n
+		}
+		draw := func(hidden bool) *mockTexture {
+			texture := newMockTexture(testTextureSize)
+			figure := newFigure("a", 0.75, 0.75)
+			figure.Size, figure.Hidden = 0.01, hidden
+			state := &painter.State{
+				BackgroundColor: color.Black,
+				Figures:         []painter.Figure{figure},
+				Texts: []painter.Text{
+					{Content: "Hello", Position: painter.Vec{X: 0.5, Y: 0.25}, Size: 0.05, Color: color.White, Align: painter.AlignCenter},
+					{Content: "label", Position: painter.Vec{Y: 0.1}, Size: 0.05, Color: color.White, Figure: "a"},
+				},
+			}
+			painter.DrawStateOp{Buffer: newMockBuffer(texture.size)}.Do(texture, state)
+			return texture
 		}
+
+		texture := draw(false)
+		if inked(texture, image.Rect(340, 180, 460, 220)) == 0 {
+			t.Error("Centered text was not drawn around its position")
+		}
+		if inked(texture, image.Rect(0, 180, 330, 220)) != 0 || inked(texture, image.Rect(470, 180, 800, 220)) != 0 {
+			t.Error("Centered text is not aligned to its position")
+		}
+		if inked(texture, image.Rect(600, 660, 800, 700)) == 0 {
+			t.Error("Label was not drawn next to its figure")
+		}
+		if inked(draw(true), image.Rect(600, 660, 800, 700)) != 0 {
+			t.Error("Label of a hidden figure was drawn")
+		}
+	})
+
+	t.Run("DrawStateOpWithSprites", func(t *testing.T) {
+		red := image.NewRGBA(image.Rect(0, 0, 4, 4))
+		draw.Draw(red, red.Bounds(), image.NewUniform(color.RGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+		assets := &painter.AssetStore{}
+		assets.Register("test-red", red)
+
+		state := painter.DefaultState()
+		texture := newMockTexture(testTextureSize)
+		painter.SpriteOp{ID: "natural", Asset: "test-red", X: 0.5, Y: 0.5}.Do(texture, state)
+		painter.SpriteOp{Asset: "test-red", W: 0.125, H: 0.25}.Do(texture, state)
+		painter.SpriteOp{Asset: "test-missing", X: 0.75, Y: 0.75}.Do(texture, state)
+		painter.SpriteRemoveOp{ID: "s3"}.Do(texture, state)
+		if len(state.Sprites) != 2 || state.Sprites[1].ID != "s2" {
+			t.Fatalf("Unexpected sprites: %v", state.Sprites)
+		}
+		state.Sprites = append(state.Sprites, painter.Sprite{ID: "missing", Asset: "test-missing", Position: painter.Vec{X: 0.75, Y: 0.75}})
+
+		painter.DrawStateOp{Buffer: newMockBuffer(texture.size), Assets: assets}.Do(texture, state)
+
+		checkPixelColor(t, texture, 401, 401, color.RGBA{R: 255, A: 255}, "Sprite is not drawn at its natural size")
+		checkPixelColor(t, texture, 405, 405, color.Black, "Sprite is larger than its natural size")
+		checkPixelColor(t, texture, 90, 190, color.RGBA{R: 255, A: 255}, "Sprite is not scaled to its size")
+		checkPixelColor(t, texture, 110, 100, color.Black, "Scaled sprite is wider than its size")
+		checkPixelColor(t, texture, 601, 601, color.Black, "Sprite with a missing asset was drawn")
+	})
+
+	t.Run("GradientOps", func(t *testing.T) {
+		state := painter.DefaultState()
+		texture := newMockTexture(testTextureSize)
+		stops := []painter.GradientStop{{Offset: 0, Color: color.Black}, {Offset: 1, Color: color.White}}
+
+		painter.GradientFillOp{Gradient: painter.NewLinearGradient(0, stops)}.Do(texture, state)
+		painter.BgRectOp{ID: "r1", X2: 0.5, Y2: 0.5}.Do(texture, state)
+		painter.RectGradientOp{ID: "r1", Gradient: painter.NewRadialGradient(painter.Vec{X: 0.5, Y: 0.5}, 0.5, stops)}.Do(texture, state)
+		if state.BackgroundGradient == nil || state.BgRects[0].Gradient == nil {
+			t.Fatalf("Gradients were not set: %v, %v", state.BackgroundGradient, state.BgRects)
+		}
+
+		painter.FillOp{Color: color.White}.Do(texture, state)
+		painter.RectColorOp{ID: "r1", Color: color.White}.Do(texture, state)
+		if state.BackgroundGradient != nil || state.BgRects[0].Gradient != nil {
+			t.Errorf("Solid colors did not replace the gradients: %v, %v", state.BackgroundGradient, state.BgRects)
+		}
+	})
+
+	t.Run("DrawStateOpWithGradients", func(t *testing.T) {
+		state := painter.DefaultState()
+		texture := newMockTexture(testTextureSize)
+		red, blue := color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}
+
+		painter.GradientFillOp{Gradient: painter.NewLinearGradient(0, []painter.GradientStop{
+			{Offset: 0, Color: color.Black}, {Offset: 1, Color: color.White},
+		})}.Do(texture, state)
+		painter.BgRectOp{ID: "split", X2: 0.25, Y2: 0.25}.Do(texture, state)
+		painter.RectGradientOp{ID: "split", Gradient: painter.NewLinearGradient(90, []painter.GradientStop{
+			{Offset: 0, Color: red}, {Offset: 0.5, Color: red}, {Offset: 0.5, Color: blue}, {Offset: 1, Color: blue},
+		})}.Do(texture, state)
+		painter.BgRectOp{ID: "spot", X1: 0.5, Y1: 0.5, X2: 1, Y2: 1}.Do(texture, state)
+		painter.RectGradientOp{ID: "spot", Gradient: painter.NewRadialGradient(painter.Vec{X: 0.5, Y: 0.5}, 0.25, []painter.GradientStop{
+			{Offset: 0, Color: red}, {Offset: 0.1, Color: red}, {Offset: 1, Color: color.Transparent},
+		})}.Do(texture, state)
+
+		painter.DrawStateOp{Buffer: newMockBuffer(texture.size)}.Do(texture, state)
+
+		checkPixelColor(t, texture, 0, 400, color.Black, "Linear gradient does not start at the left edge")
+		checkPixelColor(t, texture, 799, 0, color.White, "Linear gradient does not end at the right edge")
+		checkPixelColor(t, texture, 399, 300, color.RGBA{R: 127, G: 127, B: 127, A: 255}, "Linear gradient is not interpolated")
+		checkPixelColor(t, texture, 100, 50, red, "Vertical gradient top half is wrong")
+		checkPixelColor(t, texture, 100, 150, blue, "Vertical gradient bottom half is wrong")
+		checkPixelColor(t, texture, 600, 600, red, "Radial gradient center is wrong")
+		checkPixelColor(t, texture, 799, 799, color.White, "Radial gradient does not blend into the background")
+	})
+
+	t.Run("BlendOp", func(t *testing.T) {
+		state := painter.DefaultState()
+		texture := newMockTexture(testTextureSize)
+
+		painter.FigureOp{ID: "a", X: 0.5, Y: 0.5}.Do(texture, state)
+		painter.BgRectOp{ID: "r1", X2: 1, Y2: 1}.Do(texture, state)
+		painter.BlendOp{ID: "a", Mode: painter.BlendScreen}.Do(texture, state)
+		painter.BlendOp{ID: "r1", Mode: painter.BlendXor}.Do(texture, state)
+		painter.BlendOp{ID: "missing", Mode: painter.BlendAdd}.Do(texture, state)
+
+		if state.Figure("a").Blend != painter.BlendScreen || state.BgRects[0].Blend != painter.BlendXor {
+			t.Errorf("BlendOp did not set the blend modes: %v, %v", state.Figures, state.BgRects)
+		}
+	})
+
+	t.Run("DrawStateOpWithBlendModes", func(t *testing.T) {
+		state := painter.DefaultState()
+		texture := newMockTexture(testTextureSize)
+		red := color.RGBA{R: 255, A: 255}
+		blue := color.RGBA{B: 255, A: 255}
+
+		painter.WhiteOp{}.Do(texture, state)
+		painter.BgRectOp{ID: "base", X2: 1, Y2: 0.5, Color: red}.Do(texture, state)
+		modes := []struct {
+			mode painter.BlendMode
+			c    color.Color
+		}{
+			{painter.BlendOver, blue},
+			{painter.BlendMultiply, color.RGBA{G: 255, B: 255, A: 255}},
+			{painter.BlendScreen, blue},
+			{painter.BlendAdd, color.RGBA{G: 255, A: 255}},
+			{painter.BlendXor, blue},
+		}
+		for i, m := range modes {
+			id := string(m.mode)
+			painter.BgRectOp{ID: id, X1: float64(i) * 0.125, Y1: 0.125, X2: float64(i+1) * 0.125, Y2: 0.375, Color: m.c}.Do(texture, state)
+			painter.BlendOp{ID: id, Mode: m.mode}.Do(texture, state)
+		}
+		painter.FigureOp{ID: "dot", X: 0.875, Y: 0.25, Shape: "circle", Size: 0.1, Color: color.RGBA{G: 255, A: 255}}.Do(texture, state)
+		painter.BlendOp{ID: "dot", Mode: painter.BlendMultiply}.Do(texture, state)
+
+		painter.LayerOp{Name: "ghost"}.Do(texture, state)
+		painter.UseLayerOp{Name: "ghost"}.Do(texture, state)
+		painter.LayerOpacityOp{Name: "ghost", Opacity: 0.5}.Do(texture, state)
+		painter.BgRectOp{X1: 0, Y1: 0.5, X2: 0.5, Y2: 1, Color: red}.Do(texture, state)
+		painter.BgRectOp{X1: 0.25, Y1: 0.5, X2: 0.75, Y2: 1, Color: red}.Do(texture, state)
+
+		painter.DrawStateOp{Buffer: newMockBuffer(texture.size)}.Do(texture, state)
+
+		checkPixelColor(t, texture, 50, 200, blue, "Over blend is wrong")
+		checkPixelColor(t, texture, 150, 200, color.RGBA{A: 255}, "Multiply blend is wrong")
+		checkPixelColor(t, texture, 250, 200, color.RGBA{R: 255, B: 255, A: 255}, "Screen blend is wrong")
+		checkPixelColor(t, texture, 350, 200, color.RGBA{R: 255, G: 255, A: 255}, "Add blend is wrong")
+		checkPixelColor(t, texture, 450, 200, color.Transparent, "Xor blend is wrong")
+		checkPixelColor(t, texture, 700, 200, color.RGBA{A: 255}, "Multiplied figure is wrong")
+		checkPixelColor(t, texture, 700, 50, red, "Multiplied figure is drawn outside of its shape")
+		if single, overlap := texture.buffer.At(100, 600), texture.buffer.At(300, 600); single != overlap {
+			t.Errorf("Translucent layer elements show through each other: %v, %v", single, overlap)
+		}
+	})
+
+	t.Run("PhysicsOps", func(t *testing.T) {
+		state := painter.DefaultState()
+		texture := newMockTexture(testTextureSize)
+
+		painter.FigureOp{ID: "a", X: 0.25, Y: 0.25}.Do(texture, state)
+		painter.FigureOp{ID: "b", X: 0.75, Y: 0.75}.Do(texture, state)
+		painter.VelocityOp{X: 0.1, Y: 0.2}.Do(texture, state)
+		painter.AccelerationOp{ID: "b", Y: 0.98}.Do(texture, state)
+		painter.BoundaryOp{ID: "a", Boundary: painter.BoundaryWrap}.Do(texture, state)
+
+		a, b := state.Figure("a"), state.Figure("b")
+		if a.Velocity != (painter.Vec{X: 0.1, Y: 0.2}) || b.Velocity != a.Velocity {
+			t.Errorf("VelocityOp without an ID did not apply to every figure: %v, %v", a.Velocity, b.Velocity)
+		}
+		if a.Acceleration != (painter.Vec{}) || b.Acceleration != (painter.Vec{Y: 0.98}) {
+			t.Errorf("AccelerationOp applied to the wrong figures: %v, %v", a.Acceleration, b.Acceleration)
+		}
+		if a.Boundary != painter.BoundaryWrap || b.Boundary != "" {
+			t.Errorf("BoundaryOp applied to the wrong figures: %q, %q", a.Boundary, b.Boundary)
+		}
+	})
+
+	t.Run("LayerOps", func(t *testing.T) {
+		state := painter.DefaultState()
+		texture := newMockTexture(testTextureSize)
+
+		painter.FigureOp{ID: "a", X: 0.5, Y: 0.5}.Do(texture, state)
+		painter.UseLayerOp{Name: "hud"}.Do(texture, state)
+		painter.FigureOp{ID: "b", X: 0.25, Y: 0.25}.Do(texture, state)
+		painter.BgRectOp{ID: "r1", X2: 1, Y2: 0.125}.Do(texture, state)
+		painter.LayerOp{Name: "bottom"}.Do(texture, state)
+		painter.LayerLowerOp{Name: "bottom"}.Do(texture, state)
+		painter.LayerVisibilityOp{Name: "hud", Hidden: true}.Do(texture, state)
+		painter.LayerOpacityOp{Name: "default", Opacity: 0.5}.Do(texture, state)
+
+		if state.CurrentLayer != "hud" {
+			t.Errorf("UseLayerOp did not switch the current layer, got %q", state.CurrentLayer)
+		}
+		if state.Figure("a").Layer != painter.DefaultLayer || state.Figure("b").Layer != "hud" || state.BgRects[0].Layer != "hud" {
+			t.Errorf("Elements were not assigned to the current layer: %v, %v", state.Figures, state.BgRects)
+		}
+		expected := []painter.Layer{
+			{Name: "bottom", Opacity: 1},
+			{Name: painter.DefaultLayer, Opacity: 0.5},
+			{Name: "hud", Hidden: true, Opacity: 1},
+		}
+		if !reflect.DeepEqual(state.Layers, expected) {
+			t.Errorf("Layers mismatch. Expected: %v, Got: %v", expected, state.Layers)
+		}
+
+		painter.LayerRaiseOp{Name: "bottom"}.Do(texture, state)
+		if state.Layers[2].Name != "bottom" {
+			t.Errorf("LayerRaiseOp did not move the layer to the top: %v", state.Layers)
+		}
+	})
+
+	t.Run("DrawStateOpWithLayers", func(t *testing.T) {
+		texture := newMockTexture(testTextureSize)
+		top := bgRect("top", 0, 0, 0.5, 0.5, color.White)
+		top.Layer = "top"
+		hidden := newFigure("hidden", 0.75, 0.75)
+		hidden.Layer = "hidden"
+		state := &painter.State{
+			BackgroundColor: color.Black,
+			BgRects:         []painter.BgRect{top},
+			Figures:         []painter.Figure{newFigure("a", 0.25, 0.25), hidden},
+			Layers: []painter.Layer{
+				painter.NewLayer(painter.DefaultLayer),
+				{Name: "top", Opacity: 0.5},
+				{Name: "hidden", Hidden: true, Opacity: 1},
+			},
+		}
+
+		painter.DrawStateOp{Buffer: newMockBuffer(texture.size)}.Do(texture, state)
+
+		checkPixelColor(t, texture, 200, 200, color.RGBA{R: 255, G: 255, B: 128, A: 255}, "Upper layer is not composited with its opacity over the figure")
+		checkPixelColor(t, texture, 10, 10, color.RGBA{R: 128, G: 128, B: 128, A: 255}, "Upper layer is not composited with its opacity over the background")
+		checkPixelColor(t, texture, 600, 600, color.Black, "Hidden layer was drawn")
 	})
 
 	 t.Run("OperationList", func(t *testing.T) {
@@ -261,9 +840,9 @@ func TestOperations_Do(t *testing.T) {
 
 		needsUpdate := opList.Do(texture, state)
 
-		expectedRect := image.Rect(int(0.3*800), int(0.3*800), int(0.7*800), int(0.7*800))
-		expectedFigures := []image.Point{{X: int(0.5*800), Y: int(0.5*800)}}
-		checkState(t, state, painter.State{BackgroundColor: color.White, BgRect: &expectedRect, Figures: expectedFigures}, "State after OperationList")
+		expectedRect := bgRect("r1", 0.3, 0.3, 0.7, 0.7, color.Black)
+		expectedFigures := []painter.Figure{newFigure("f1", 0.5, 0.5)}
+		checkState(t, state, painter.State{BackgroundColor: color.White, BgRects: []painter.BgRect{expectedRect}, Figures: expectedFigures}, "State after OperationList")
 
 		if !needsUpdate {
 			t.Error("OperationList returned needsUpdate = false unexpectedly")
@@ -286,7 +865,7 @@ func TestOperations_Do(t *testing.T) {
 
 		needsUpdate := opFunc.Do(texture, state)
 
-		checkState(t, state, painter.State{BackgroundColor: color.Black, BgRect: nil, Figures: []image.Point{}}, "State after OperationFunc")
+		checkState(t, state, painter.State{BackgroundColor: color.Black, Figures: []painter.Figure{}}, "State after OperationFunc")
 		if needsUpdate {
 			t.Error("OperationFunc returned needsUpdate = true unexpectedly")
 		}
@@ -0,0 +1,508 @@
+package painter
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+type PathKind string
+
+const (
+	PathLine     PathKind = "line"
+	PathPolyline PathKind = "polyline"
+	PathQuad     PathKind = "quad"
+	PathCubic    PathKind = "cubic"
+)
+
+type LineCap string
+
+const (
+	CapButt   LineCap = "butt"
+	CapRound  LineCap = "round"
+	CapSquare LineCap = "square"
+)
+
+type LineJoin string
+
+const (
+	JoinMiter LineJoin = "miter"
+	JoinRound LineJoin = "round"
+	JoinBevel LineJoin = "bevel"
+)
+
+// Miters longer than miterLimit stroke widths are drawn as bevels.
+const miterLimit = 4
+
+const DefaultStrokeWidth = 0.005
+
+// MaxDashEntries bounds the length of a dash pattern. A pattern shorter than
+// the stroke width is rejected as well, since its dashes would blur into a
+// solid line anyway.
+const MaxDashEntries = 16
+
+var DefaultStrokeColor color.Color = color.White
+
+// Stroke widths and dash lengths are relative to the smaller side of the
+// texture, like figure sizes.
+type Stroke struct {
+	Width float64
+	Color color.Color
+	// Dash holds alternating dash and gap lengths; an empty pattern means a
+	// solid line.
+	Dash []float64
+	Cap  LineCap
+	Join LineJoin
+}
+
+// Path is a stroked line, polyline or Bézier curve. Points are relative to
+// the texture size; quad and cubic paths list the start point, the control
+// points and the end point.
+type Path struct {
+	ID     string
+	Kind   PathKind
+	Points []Vec
+	Stroke
+	Layer string
+}
+
+func ValidatePath(kind PathKind, points int) error {
+	switch kind {
+	case PathLine:
+		if points != 2 {
+			return fmt.Errorf("line requires 2 points")
+		}
+	case PathPolyline:
+		if points < 2 {
+			return fmt.Errorf("polyline requires at least 2 points")
+		}
+	case PathQuad:
+		if points != 3 {
+			return fmt.Errorf("quad requires 3 points")
+		}
+	case PathCubic:
+		if points != 4 {
+			return fmt.Errorf("cubic requires 4 points")
+		}
+	default:
+		return fmt.Errorf("unknown path kind: %s", kind)
+	}
+	return nil
+}
+
+func ValidateStroke(s Stroke) error {
+	switch s.Cap {
+	case "", CapButt, CapRound, CapSquare:
+	default:
+		return fmt.Errorf("unknown line cap: %s", s.Cap)
+	}
+	switch s.Join {
+	case "", JoinMiter, JoinRound, JoinBevel:
+	default:
+		return fmt.Errorf("unknown line join: %s", s.Join)
+	}
+	if s.Width < 0 {
+		return fmt.Errorf("stroke width must not be negative")
+	}
+	if len(s.Dash) > MaxDashEntries {
+		return fmt.Errorf("dash pattern must have at most %d entries", MaxDashEntries)
+	}
+	var total float64
+	for _, d := range s.Dash {
+		if d < 0 {
+			return fmt.Errorf("dash lengths must not be negative")
+		}
+		total += d
+	}
+	width := s.Width
+	if width == 0 {
+		width = DefaultStrokeWidth
+	}
+	if total > 0 && total < width {
+		return fmt.Errorf("dash pattern must be at least as long as the stroke width")
+	}
+	return nil
+}
+
+func (s *State) pathIndex(id string) int {
+	for i := range s.Paths {
+		if s.Paths[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *State) newPathID() string {
+	for n := len(s.Paths) + 1; ; n++ {
+		id := fmt.Sprintf("p%d", n)
+		if s.pathIndex(id) < 0 {
+			return id
+		}
+	}
+}
+
+// PathOp adds a path to the current layer, replacing the path with the same
+// ID if there is one. Zero stroke fields are replaced with defaults.
+type PathOp struct {
+	ID     string
+	Kind   PathKind
+	Points []Vec
+	Stroke Stroke
+}
+
+func (op PathOp) Do(t screen.Texture, s *State) bool {
+	path := Path{
+		ID:     op.ID,
+		Kind:   op.Kind,
+		Points: append([]Vec{}, op.Points...),
+		Stroke: op.Stroke,
+		Layer:  s.currentLayer(),
+	}
+	s.ensureLayer(path.Layer)
+	if path.Width == 0 {
+		path.Width = DefaultStrokeWidth
+	}
+	if path.Color == nil {
+		path.Color = DefaultStrokeColor
+	}
+	if path.Cap == "" {
+		path.Cap = CapButt
+	}
+	if path.Join == "" {
+		path.Join = JoinMiter
+	}
+	if path.ID == "" {
+		path.ID = s.newPathID()
+	}
+
+	if i := s.pathIndex(path.ID); i >= 0 {
+		s.Paths[i] = path
+	} else {
+		s.Paths = append(s.Paths, path)
+	}
+	return false
+}
+
+type PathRemoveOp struct {
+	ID string
+}
+
+func (op PathRemoveOp) Do(t screen.Texture, s *State) bool {
+	if i := s.pathIndex(op.ID); i >= 0 {
+		s.Paths = append(s.Paths[:i], s.Paths[i+1:]...)
+	}
+	return false
+}
+
+// flatten converts the path into a polyline in pixel space.
+func (p Path) flatten(bounds image.Rectangle) []Vec {
+	points := make([]Vec, len(p.Points))
+	for i, v := range p.Points {
+		points[i] = toPixels(v, bounds)
+	}
+	switch p.Kind {
+	case PathQuad, PathCubic:
+		var length float64
+		for i := 1; i < len(points); i++ {
+			length += distance(points[i-1], points[i])
+		}
+		steps := int(math.Max(1, math.Min(256, math.Ceil(length/4))))
+		curve := make([]Vec, 0, steps+1)
+		for i := 0; i <= steps; i++ {
+			curve = append(curve, bezier(points, float64(i)/float64(steps)))
+		}
+		return curve
+	default:
+		return points
+	}
+}
+
+// bezier evaluates the curve with de Casteljau's algorithm.
+func bezier(points []Vec, t float64) Vec {
+	p := append([]Vec{}, points...)
+	for n := len(p) - 1; n > 0; n-- {
+		for i := 0; i < n; i++ {
+			p[i] = lerpVec(p[i], p[i+1], t)
+		}
+	}
+	return p[0]
+}
+
+func lerpVec(a, b Vec, t float64) Vec {
+	return Vec{X: a.X + (b.X-a.X)*t, Y: a.Y + (b.Y-a.Y)*t}
+}
+
+func distance(a, b Vec) float64 {
+	return math.Hypot(b.X-a.X, b.Y-a.Y)
+}
+
+// maxDashes bounds the number of dashes drawn for a path, so that a tiny
+// pattern cannot make it arbitrarily slow to draw. Longer patterns are
+// stretched to fit.
+const maxDashes = 4096
+
+// strokePolygons outlines a polyline of the given width in pixels, leaving out
+// the parts outside clip. The returned polygons overlap and have to be filled
+// as a union.
+func strokePolygons(points []Vec, s Stroke, width, unit float64, clip Rect) [][]Vec {
+	pattern := dashPattern(s.Dash, unit)
+	if pattern != nil {
+		var cycle float64
+		for _, d := range pattern {
+			cycle += d
+		}
+		if n := clippedLength(points, clip) / cycle * float64(len(pattern)/2); n > maxDashes {
+			for i := range pattern {
+				pattern[i] *= n / maxDashes
+			}
+		}
+	}
+	var polygons [][]Vec
+	for _, dash := range dashPolyline(points, pattern, clip) {
+		polygons = append(polygons, strokePolyline(dash, s, width/2)...)
+	}
+	return polygons
+}
+
+// strokeClip returns the area outside of which a stroke of the given width in
+// pixels cannot touch the bounds, even with its caps and miters.
+func strokeClip(bounds image.Rectangle, width float64) Rect {
+	margin := width/2*miterLimit + 1
+	return Rect{
+		Min: Vec{X: float64(bounds.Min.X) - margin, Y: float64(bounds.Min.Y) - margin},
+		Max: Vec{X: float64(bounds.Max.X) + margin, Y: float64(bounds.Max.Y) + margin},
+	}
+}
+
+func dashPattern(dash []float64, unit float64) []float64 {
+	var total float64
+	pattern := make([]float64, 0, len(dash)*2)
+	for _, d := range dash {
+		pattern = append(pattern, d*unit)
+		total += d * unit
+	}
+	if total <= 0 {
+		return nil
+	}
+	if len(pattern)%2 == 1 {
+		pattern = append(pattern, pattern...)
+	}
+	return pattern
+}
+
+// clipSegment returns the part of the segment from a to b within r as an
+// interval of the segment parameter, or false when the segment misses r.
+func clipSegment(a, b Vec, r Rect) (t0, t1 float64, ok bool) {
+	t0, t1 = 0, 1
+	dx, dy := b.X-a.X, b.Y-a.Y
+	for _, e := range [4][2]float64{
+		{-dx, a.X - r.Min.X}, {dx, r.Max.X - a.X},
+		{-dy, a.Y - r.Min.Y}, {dy, r.Max.Y - a.Y},
+	} {
+		p, q := e[0], e[1]
+		switch {
+		case p == 0:
+			if q < 0 {
+				return 0, 0, false
+			}
+		case p < 0:
+			t0 = math.Max(t0, q/p)
+		default:
+			t1 = math.Min(t1, q/p)
+		}
+	}
+	return t0, t1, t0 <= t1
+}
+
+func clippedLength(points []Vec, clip Rect) float64 {
+	var length float64
+	for k := 1; k < len(points); k++ {
+		if t0, t1, ok := clipSegment(points[k-1], points[k], clip); ok {
+			length += (t1 - t0) * distance(points[k-1], points[k])
+		}
+	}
+	return length
+}
+
+// dashPolyline splits the polyline into the dashes of the pattern, or into
+// the runs of a solid line when the pattern is nil. Only the parts within
+// clip are kept, while the pattern carries on over the rest.
+func dashPolyline(points []Vec, pattern []float64, clip Rect) [][]Vec {
+	var cycle float64
+	for _, d := range pattern {
+		cycle += d
+	}
+	i, remaining, on := 0, math.Inf(1), true
+	if pattern != nil {
+		remaining = pattern[0]
+	}
+	next := func() {
+		i, on = (i+1)%len(pattern), !on
+		remaining = pattern[i]
+	}
+	// skip advances the pattern over a part of the path that is not drawn.
+	skip := func(d float64) {
+		if d <= 0 || d < remaining {
+			remaining -= d
+			return
+		}
+		d -= remaining
+		next()
+		d = math.Mod(d, cycle)
+		for d >= remaining {
+			d -= remaining
+			next()
+		}
+		remaining -= d
+	}
+
+	var dashes [][]Vec
+	var current []Vec
+	for k := 1; k < len(points); k++ {
+		a, b := points[k-1], points[k]
+		length := distance(a, b)
+		t0, t1, ok := clipSegment(a, b, clip)
+		if !ok {
+			skip(length)
+			continue
+		}
+		if t0 > 0 {
+			skip(t0 * length)
+		}
+		if current == nil || t0 > 0 {
+			current = []Vec{lerpVec(a, b, t0)}
+		}
+		pos, end := t0*length, t1*length
+		for end-pos > remaining {
+			pos += remaining
+			p := lerpVec(a, b, pos/length)
+			if on {
+				dashes = append(dashes, append(current, p))
+			}
+			current = []Vec{p}
+			next()
+		}
+		remaining -= end - pos
+		if t1 < 1 {
+			if on {
+				dashes = append(dashes, append(current, lerpVec(a, b, t1)))
+			}
+			current = nil
+			skip((1 - t1) * length)
+		} else if on {
+			current = append(current, b)
+		}
+	}
+	if on && current != nil {
+		dashes = append(dashes, current)
+	}
+	return dashes
+}
+
+func strokePolyline(points []Vec, s Stroke, half float64) [][]Vec {
+	points = dedupe(points)
+	if len(points) == 1 {
+		switch s.Cap {
+		case CapRound:
+			return [][]Vec{circlePolygon(points[0], half)}
+		case CapSquare:
+			p := points[0]
+			return [][]Vec{rectPolygon(p.X-half, p.Y-half, p.X+half, p.Y+half)}
+		}
+		return nil
+	}
+
+	var polygons [][]Vec
+	last := len(points) - 1
+	for i := 0; i < last; i++ {
+		a, b := points[i], points[i+1]
+		d := direction(a, b)
+		if s.Cap == CapSquare {
+			if i == 0 {
+				a = Vec{X: a.X - d.X*half, Y: a.Y - d.Y*half}
+			}
+			if i == last-1 {
+				b = Vec{X: b.X + d.X*half, Y: b.Y + d.Y*half}
+			}
+		}
+		n := Vec{X: -d.Y * half, Y: d.X * half}
+		polygons = append(polygons, []Vec{
+			{X: a.X + n.X, Y: a.Y + n.Y},
+			{X: b.X + n.X, Y: b.Y + n.Y},
+			{X: b.X - n.X, Y: b.Y - n.Y},
+			{X: a.X - n.X, Y: a.Y - n.Y},
+		})
+	}
+
+	for i := 1; i < last; i++ {
+		if join := joinPolygon(points[i-1], points[i], points[i+1], s.Join, half); join != nil {
+			polygons = append(polygons, join)
+		}
+	}
+
+	if s.Cap == CapRound {
+		polygons = append(polygons, circlePolygon(points[0], half), circlePolygon(points[last], half))
+	}
+	return polygons
+}
+
+func joinPolygon(a, p, b Vec, join LineJoin, half float64) []Vec {
+	if join == JoinRound {
+		return circlePolygon(p, half)
+	}
+
+	d1, d2 := direction(a, p), direction(p, b)
+	cross := d1.X*d2.Y - d1.Y*d2.X
+	if math.Abs(cross) < 1e-9 {
+		return nil
+	}
+	// The join is only needed on the outer side of the turn.
+	side := half
+	if cross > 0 {
+		side = -half
+	}
+	n1 := Vec{X: -d1.Y, Y: d1.X}
+	n2 := Vec{X: -d2.Y, Y: d2.X}
+	o1 := Vec{X: p.X + n1.X*side, Y: p.Y + n1.Y*side}
+	o2 := Vec{X: p.X + n2.X*side, Y: p.Y + n2.Y*side}
+
+	if join == JoinMiter {
+		m := Vec{X: n1.X + n2.X, Y: n1.Y + n2.Y}
+		if ml := math.Hypot(m.X, m.Y); ml > 1e-9 {
+			m = Vec{X: m.X / ml, Y: m.Y / ml}
+			if ratio := 1 / (m.X*n1.X + m.Y*n1.Y); ratio <= miterLimit {
+				tip := Vec{X: p.X + m.X*side*ratio, Y: p.Y + m.Y*side*ratio}
+				return []Vec{p, o1, tip, o2}
+			}
+		}
+	}
+	return []Vec{p, o1, o2}
+}
+
+func direction(a, b Vec) Vec {
+	l := distance(a, b)
+	return Vec{X: (b.X - a.X) / l, Y: (b.Y - a.Y) / l}
+}
+
+func dedupe(points []Vec) []Vec {
+	result := points[:1:1]
+	for _, p := range points[1:] {
+		if p != result[len(result)-1] {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+func circlePolygon(center Vec, r float64) []Vec {
+	vertices := regularPolygon(32, r, 0)
+	for i := range vertices {
+		vertices[i].X += center.X
+		vertices[i].Y += center.Y
+	}
+	return vertices
+}
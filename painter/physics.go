@@ -0,0 +1,169 @@
+package painter
+
+import (
+	"image"
+	"math"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// Boundary selects what a moving figure does when it reaches the edge of the
+// canvas. The empty boundary lets it leave the canvas.
+type Boundary string
+
+const (
+	BoundaryBounce Boundary = "bounce"
+	BoundaryWrap   Boundary = "wrap"
+	BoundaryClamp  Boundary = "clamp"
+	BoundaryStop   Boundary = "stop"
+)
+
+func ValidBoundary(b Boundary) bool {
+	switch b {
+	case "", BoundaryBounce, BoundaryWrap, BoundaryClamp, BoundaryStop:
+		return true
+	}
+	return false
+}
+
+// VelocityOp sets the velocity of the figure with the given ID, or of every
+// figure when ID is empty, in canvas units per second.
+type VelocityOp struct {
+	ID   string
+	X, Y float64
+}
+
+func (op VelocityOp) Do(t screen.Texture, s *State) bool {
+	for i := range s.Figures {
+		if op.ID == "" || s.Figures[i].ID == op.ID {
+			s.Figures[i].Velocity = Vec{X: op.X, Y: op.Y}
+		}
+	}
+	return false
+}
+
+// AccelerationOp sets the acceleration of the figure with the given ID, or
+// of every figure when ID is empty, in canvas units per second squared.
+type AccelerationOp struct {
+	ID   string
+	X, Y float64
+}
+
+func (op AccelerationOp) Do(t screen.Texture, s *State) bool {
+	for i := range s.Figures {
+		if op.ID == "" || s.Figures[i].ID == op.ID {
+			s.Figures[i].Acceleration = Vec{X: op.X, Y: op.Y}
+		}
+	}
+	return false
+}
+
+type BoundaryOp struct {
+	ID       string
+	Boundary Boundary
+}
+
+func (op BoundaryOp) Do(t screen.Texture, s *State) bool {
+	for i := range s.Figures {
+		if op.ID == "" || s.Figures[i].ID == op.ID {
+			s.Figures[i].Boundary = op.Boundary
+		}
+	}
+	return false
+}
+
+func (f Figure) moving() bool {
+	return f.Velocity != (Vec{}) || f.Acceleration != (Vec{})
+}
+
+// extent returns the bounding box of the figure relative to a canvas of the
+// given size.
+func (f Figure) extent(canvas image.Point) (lo, hi Vec, ok bool) {
+	shape, err := f.shape()
+	if err != nil || canvas.X <= 0 || canvas.Y <= 0 {
+		return Vec{}, Vec{}, false
+	}
+	unit := float64(min(canvas.X, canvas.Y)) * f.Size
+	lo = Vec{X: math.Inf(1), Y: math.Inf(1)}
+	hi = Vec{X: math.Inf(-1), Y: math.Inf(-1)}
+	for _, polygon := range Rotated(shape, f.Rotation).Polygons() {
+		for _, v := range polygon {
+			p := Vec{X: f.Center.X + v.X*unit/float64(canvas.X), Y: f.Center.Y + v.Y*unit/float64(canvas.Y)}
+			lo = Vec{X: math.Min(lo.X, p.X), Y: math.Min(lo.Y, p.Y)}
+			hi = Vec{X: math.Max(hi.X, p.X), Y: math.Max(hi.Y, p.Y)}
+		}
+	}
+	return lo, hi, lo.X <= hi.X
+}
+
+// step advances the moving figures by dt seconds and reports whether there
+// was any.
+func (s *State) step(dt float64, canvas image.Point) bool {
+	moved := false
+	for i := range s.Figures {
+		f := &s.Figures[i]
+		if !f.moving() {
+			continue
+		}
+		f.Velocity.X += f.Acceleration.X * dt
+		f.Velocity.Y += f.Acceleration.Y * dt
+		f.Center.X += f.Velocity.X * dt
+		f.Center.Y += f.Velocity.Y * dt
+		if f.Boundary != "" {
+			f.keepInside(canvas)
+		}
+		moved = true
+	}
+	return moved
+}
+
+// keepInside applies the boundary of the figure to both axes. Wrapping
+// figures leave the canvas completely before they come back on the other
+// side.
+func (f *Figure) keepInside(canvas image.Point) {
+	lo, hi, ok := f.extent(canvas)
+	if !ok {
+		return
+	}
+	stopped := false
+	axes := []struct {
+		center, velocity *float64
+		lo, hi           float64
+	}{
+		{&f.Center.X, &f.Velocity.X, lo.X, hi.X},
+		{&f.Center.Y, &f.Velocity.Y, lo.Y, hi.Y},
+	}
+	for _, a := range axes {
+		switch {
+		case f.Boundary == BoundaryWrap && a.hi < 0:
+			*a.center += 1 + a.hi - a.lo
+		case f.Boundary == BoundaryWrap && a.lo > 1:
+			*a.center -= 1 + a.hi - a.lo
+		case f.Boundary == BoundaryWrap:
+		case a.lo < 0:
+			*a.center -= a.lo
+			stopped = f.bounce(a.velocity, 1) || stopped
+		case a.hi > 1:
+			*a.center -= a.hi - 1
+			stopped = f.bounce(a.velocity, -1) || stopped
+		}
+	}
+	if stopped {
+		f.Velocity, f.Acceleration = Vec{}, Vec{}
+	}
+}
+
+// bounce updates the velocity along an axis after the figure has hit an
+// edge; direction points back into the canvas. It reports whether the figure
+// has to stop altogether.
+func (f *Figure) bounce(velocity *float64, direction float64) bool {
+	switch f.Boundary {
+	case BoundaryBounce:
+		*velocity = direction * math.Abs(*velocity)
+	case BoundaryClamp:
+		*velocity = 0
+	case BoundaryStop:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package painter
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestState_Step(t *testing.T) {
+	canvas := image.Pt(100, 100)
+	box := func(x float64, v Vec, b Boundary) Figure {
+		f := NewFigure("a", Vec{X: x, Y: 0.5})
+		f.Shape, f.Size, f.Velocity, f.Boundary = "rectangle", 0.2, v, b
+		return f
+	}
+	tests := []struct {
+		name     string
+		figure   Figure
+		center   Vec
+		velocity Vec
+	}{
+		{"free", box(0.5, Vec{X: 1, Y: 1}, ""), Vec{X: 0.6, Y: 0.6}, Vec{X: 1, Y: 1}},
+		{"leaving without a boundary", box(0.85, Vec{X: 1}, ""), Vec{X: 0.95, Y: 0.5}, Vec{X: 1}},
+		{"bounce", box(0.85, Vec{X: 1, Y: 0.5}, BoundaryBounce), Vec{X: 0.9, Y: 0.55}, Vec{X: -1, Y: 0.5}},
+		{"clamp", box(0.85, Vec{X: 1, Y: 0.5}, BoundaryClamp), Vec{X: 0.9, Y: 0.55}, Vec{Y: 0.5}},
+		{"stop", box(0.85, Vec{X: 1, Y: 0.5}, BoundaryStop), Vec{X: 0.9, Y: 0.55}, Vec{}},
+		{"wrap inside", box(0.85, Vec{X: 1}, BoundaryWrap), Vec{X: 0.95, Y: 0.5}, Vec{X: 1}},
+		{"wrap", box(1.05, Vec{X: 1}, BoundaryWrap), Vec{X: -0.05, Y: 0.5}, Vec{X: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := DefaultState()
+			s.Figures = []Figure{tt.figure}
+			if !s.step(0.1, canvas) {
+				t.Fatal("Moving figure was not reported")
+			}
+			f := s.Figures[0]
+			if math.Abs(f.Center.X-tt.center.X) > 1e-9 || math.Abs(f.Center.Y-tt.center.Y) > 1e-9 {
+				t.Errorf("Expected center %v, got %v", tt.center, f.Center)
+			}
+			if math.Abs(f.Velocity.X-tt.velocity.X) > 1e-9 || math.Abs(f.Velocity.Y-tt.velocity.Y) > 1e-9 {
+				t.Errorf("Expected velocity %v, got %v", tt.velocity, f.Velocity)
+			}
+		})
+	}
+
+	t.Run("acceleration", func(t *testing.T) {
+		s := DefaultState()
+		f := NewFigure("a", Vec{X: 0.5, Y: 0.5})
+		f.Acceleration = Vec{Y: 1}
+		s.Figures = []Figure{f}
+		s.step(0.5, canvas)
+		s.step(0.5, canvas)
+		if got := s.Figures[0]; got.Velocity != (Vec{Y: 1}) || math.Abs(got.Center.Y-1.25) > 1e-9 {
+			t.Errorf("Unexpected motion under acceleration: %v at %v", got.Velocity, got.Center)
+		}
+	})
+
+	t.Run("still", func(t *testing.T) {
+		s := DefaultState()
+		s.Figures = []Figure{NewFigure("a", Vec{X: 0.5, Y: 0.5})}
+		if s.step(0.1, canvas) {
+			t.Error("Figure without velocity was reported as moving")
+		}
+	})
+}
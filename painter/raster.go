@@ -0,0 +1,177 @@
+package painter
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"log"
+	"math"
+
+	"golang.org/x/image/vector"
+)
+
+// Render rasterizes the state into dst, which is treated as the whole canvas.
+// Sprites are drawn with the images registered in assets.
+func Render(dst *image.RGBA, s *State, assets *AssetStore) {
+	bounds := dst.Bounds()
+	if s.BackgroundGradient != nil {
+		draw.Draw(dst, bounds, image.Transparent, image.Point{}, draw.Src)
+		fillGradient(dst, bounds, s.BackgroundGradient, BlendOver)
+	} else {
+		draw.Draw(dst, bounds, image.NewUniform(s.BackgroundColor), image.Point{}, draw.Src)
+	}
+
+	for _, layer := range s.drawingLayers() {
+		if layer.Hidden || layer.Opacity <= 0 {
+			continue
+		}
+		if layer.Opacity >= 1 {
+			s.renderLayer(dst, layer, assets)
+			continue
+		}
+		// A translucent layer is drawn on its own and composited as a whole,
+		// so its elements do not show through each other.
+		group := image.NewRGBA(bounds)
+		s.renderLayer(group, layer, assets)
+		mask := image.NewUniform(color.Alpha{A: uint8(math.Round(layer.Opacity * 0xff))})
+		draw.DrawMask(dst, bounds, group, bounds.Min, mask, image.Point{}, draw.Over)
+	}
+}
+
+func (s *State) renderLayer(dst *image.RGBA, layer Layer, assets *AssetStore) {
+	bounds := dst.Bounds()
+	unit := float64(min(bounds.Dx(), bounds.Dy()))
+
+	for _, r := range s.BgRects {
+		if !onLayer(r.Layer, layer) {
+			continue
+		}
+		area := r.pixels(bounds.Size()).Add(bounds.Min)
+		if r.Gradient != nil {
+			fillGradient(dst, area, r.Gradient, r.Blend)
+		} else {
+			blendRect(dst, area, r.Color, r.Blend)
+		}
+	}
+
+	for _, sp := range s.Sprites {
+		if onLayer(sp.Layer, layer) {
+			drawSprite(dst, sp, bounds, assets)
+		}
+	}
+
+	for _, p := range s.Paths {
+		if !onLayer(p.Layer, layer) {
+			continue
+		}
+		polygons := strokePolygons(p.flatten(bounds), p.Stroke, p.Width*unit, unit, strokeClip(bounds, p.Width*unit))
+		fillPolygons(dst, polygons, image.NewUniform(p.Color))
+	}
+
+	for _, f := range s.Figures {
+		if f.Hidden || !onLayer(f.Layer, layer) {
+			continue
+		}
+		shape, err := f.shape()
+		if err != nil {
+			continue
+		}
+		polygons := shapePolygons(Rotated(shape, f.Rotation), toPixels(f.Center, bounds), f.Size*unit)
+		blendPolygons(dst, polygons, fadeColor(f.Color, f.Alpha), f.Blend)
+	}
+
+	for _, text := range s.Texts {
+		at, ok := s.anchor(text)
+		if !ok || !onLayer(text.Layer, layer) {
+			continue
+		}
+		if err := DrawText(dst, text.Content, toPixels(at, bounds), text.Size*unit, text.Align, text.Color); err != nil {
+			log.Printf("Failed to draw text %s: %s", text.ID, err)
+		}
+	}
+}
+
+// toPixels converts a point relative to the canvas into pixel coordinates.
+func toPixels(v Vec, bounds image.Rectangle) Vec {
+	return Vec{
+		X: float64(bounds.Min.X) + v.X*float64(bounds.Dx()),
+		Y: float64(bounds.Min.Y) + v.Y*float64(bounds.Dy()),
+	}
+}
+
+// DrawShape composites the anti-aliased shape over dst. Overlapping polygons
+// of the shape are filled as their union, so translucent shapes are not
+// blended twice where their parts intersect.
+func DrawShape(dst draw.Image, shape Shape, center Vec, size float64, c color.Color) {
+	fillPolygons(dst, shapePolygons(shape, center, size), image.NewUniform(c))
+}
+
+// shapePolygons places the shape polygons at center in pixels.
+func shapePolygons(shape Shape, center Vec, size float64) [][]Vec {
+	var polygons [][]Vec
+	for _, polygon := range shape.Polygons() {
+		points := make([]Vec, len(polygon))
+		for i, v := range polygon {
+			points[i] = Vec{X: center.X + v.X*size, Y: center.Y + v.Y*size}
+		}
+		polygons = append(polygons, points)
+	}
+	return polygons
+}
+
+// fillPolygons rasterizes only the area covered by the polygons. All of them
+// are traced in the same direction because the rasterizer cancels out
+// overlapping areas of opposite orientation.
+func fillPolygons(dst draw.Image, polygons [][]Vec, src image.Image) {
+	area := polygonBounds(polygons).Intersect(dst.Bounds())
+	if area.Empty() {
+		return
+	}
+
+	z := vector.NewRasterizer(area.Dx(), area.Dy())
+	origin := Vec{X: float64(area.Min.X), Y: float64(area.Min.Y)}
+	for _, points := range polygons {
+		if len(points) < 3 {
+			continue
+		}
+		reversed := signedArea(points) < 0
+		for i := range points {
+			p := points[i]
+			if reversed {
+				p = points[len(points)-1-i]
+			}
+			x, y := float32(p.X-origin.X), float32(p.Y-origin.Y)
+			if i == 0 {
+				z.MoveTo(x, y)
+			} else {
+				z.LineTo(x, y)
+			}
+		}
+		z.ClosePath()
+	}
+	z.Draw(dst, area, src, area.Min)
+}
+
+func polygonBounds(polygons [][]Vec) image.Rectangle {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, points := range polygons {
+		for _, p := range points {
+			minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+			minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+		}
+	}
+	if minX > maxX {
+		return image.Rectangle{}
+	}
+	return image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY)))
+}
+
+func signedArea(points []Vec) float64 {
+	var area float64
+	for i := range points {
+		a, b := points[i], points[(i+1)%len(points)]
+		area += a.X*b.Y - b.X*a.Y
+	}
+	return area / 2
+}
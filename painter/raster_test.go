@@ -0,0 +1,93 @@
+package painter
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+func TestDrawShape_AntiAliasing(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	draw.Draw(img, img.Bounds(), image.Black, image.Point{}, draw.Src)
+
+	shape, err := ParseShape("circle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	DrawShape(img, shape, Vec{X: 50, Y: 50}, 80, color.White)
+
+	if got := img.RGBAAt(50, 50); got != (color.RGBA{R: 255, G: 255, B: 255, A: 255}) {
+		t.Errorf("Circle center is not filled: %v", got)
+	}
+	if got := img.RGBAAt(5, 5); got != (color.RGBA{A: 255}) {
+		t.Errorf("Pixel outside the circle is filled: %v", got)
+	}
+
+	// The edge crosses pixel (78, 78) diagonally, so it must be partially covered.
+	if got := img.RGBAAt(78, 78); got.R == 0 || got.R == 255 {
+		t.Errorf("Circle edge is not anti-aliased: %v", got)
+	}
+}
+
+func TestDrawShape_ClipsToDestination(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+
+	shape, err := ParseShape("rectangle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	DrawShape(img, shape, Vec{X: 0, Y: 0}, 10, color.White)
+	DrawShape(img, shape, Vec{X: -100, Y: -100}, 10, color.White)
+
+	if got := img.RGBAAt(2, 2); got.A != 255 {
+		t.Errorf("Visible part of a partially clipped shape is not drawn: %v", got)
+	}
+	if got := img.RGBAAt(7, 7); got.A != 0 {
+		t.Errorf("Pixel outside the shape is filled: %v", got)
+	}
+}
+
+func TestStrokePolygons_BoundsDashes(t *testing.T) {
+	bounds := image.Rect(0, 0, 400, 200)
+	clip := strokeClip(bounds, 2)
+
+	// A pattern far below a pixel would otherwise produce millions of dashes.
+	line := Path{Kind: PathLine, Points: []Vec{{X: 0, Y: 0}, {X: 1, Y: 1}}}
+	polygons := strokePolygons(line.flatten(bounds), Stroke{Dash: []float64{1e-7}}, 2, 200, clip)
+	// The stretched pattern may still leave a partial dash at the end.
+	if len(polygons) > maxDashes+1 {
+		t.Errorf("Tiny dash pattern produced %d polygons", len(polygons))
+	}
+
+	// Dashes far outside the texture are never built.
+	cubic := Path{Kind: PathCubic, Points: []Vec{{X: 0, Y: 0}, {X: 1e6, Y: 1e6}, {X: -1e6, Y: 1}, {X: 1, Y: 1}}}
+	polygons = strokePolygons(cubic.flatten(bounds), Stroke{Dash: []float64{0.01}}, 2, 200, clip)
+	if len(polygons) > maxDashes+1 {
+		t.Errorf("Huge curve produced %d polygons", len(polygons))
+	}
+	for _, polygon := range polygons {
+		for _, v := range polygon {
+			if v.X < clip.Min.X-2 || v.X > clip.Max.X+2 || v.Y < clip.Min.Y-2 || v.Y > clip.Max.Y+2 {
+				t.Fatalf("Dash point %v lies outside the canvas", v)
+			}
+		}
+	}
+}
+
+func TestDashPolyline_KeepsPhaseAcrossClip(t *testing.T) {
+	clip := Rect{Min: Vec{X: 0, Y: -10}, Max: Vec{X: 100, Y: 10}}
+	dashes := dashPolyline([]Vec{{X: -105, Y: 0}, {X: 200, Y: 0}}, []float64{10, 10}, clip)
+
+	want := [][2]float64{{0, 5}, {15, 25}, {35, 45}, {55, 65}, {75, 85}, {95, 100}}
+	if len(dashes) != len(want) {
+		t.Fatalf("Expected %d dashes, got %v", len(want), dashes)
+	}
+	for i, dash := range dashes {
+		start, end := dash[0].X, dash[len(dash)-1].X
+		if math.Abs(start-want[i][0]) > 1e-9 || math.Abs(end-want[i][1]) > 1e-9 {
+			t.Errorf("Dash %d spans [%g, %g], expected %v", i, start, end, want[i])
+		}
+	}
+}
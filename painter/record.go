@@ -0,0 +1,248 @@
+package painter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// MaxRecordingSize bounds the memory taken by the frames of a recording,
+// which is about 100 frames of 400x400 pixels. Recording stops on its own
+// when it is reached.
+const MaxRecordingSize = 64 << 20
+
+// maxPaletteSamples bounds the number of pixels the palette of a recording
+// is built from.
+const maxPaletteSamples = 1 << 20
+
+// ErrNoRecording is returned when nothing has been recorded yet.
+var ErrNoRecording = errors.New("nothing has been recorded")
+
+// RecordOp makes the Loop record every frame it sends to its Receiver,
+// starting with the one on the screen. A recording in progress is restarted.
+type RecordOp struct{}
+
+func (op RecordOp) Do(t screen.Texture, s *State) bool {
+	return false
+}
+
+// StopRecordingOp stops recording and encodes the frames into an animated
+// GIF, which is available from Loop.Recording afterwards.
+type StopRecordingOp struct{}
+
+func (op StopRecordingOp) Do(t screen.Texture, s *State) bool {
+	return false
+}
+
+type recordedFrame struct {
+	img *image.RGBA
+	at  time.Time
+}
+
+// recorder keeps the frames of a Loop while it is recording. The last
+// recording is encoded in the background.
+type recorder struct {
+	frames    []recordedFrame
+	size      int
+	recording bool
+
+	mu      sync.Mutex
+	encoded chan struct{}
+	gif     []byte
+	err     error
+}
+
+func (r *recorder) start() {
+	r.frames, r.size, r.recording = nil, 0, true
+}
+
+// capture copies a frame of a recording in progress.
+func (r *recorder) capture(img *image.RGBA, now time.Time) {
+	if !r.recording {
+		return
+	}
+	if r.size+len(img.Pix) > MaxRecordingSize {
+		log.Printf("Recording has reached %d bytes, stopping it", MaxRecordingSize)
+		r.stop(now)
+		return
+	}
+	frame := image.NewRGBA(img.Bounds())
+	copy(frame.Pix, img.Pix)
+	r.frames = append(r.frames, recordedFrame{img: frame, at: now})
+	r.size += len(frame.Pix)
+}
+
+func (r *recorder) stop(now time.Time) {
+	if !r.recording {
+		return
+	}
+	frames := r.frames
+	r.frames, r.size, r.recording = nil, 0, false
+
+	encoded := make(chan struct{})
+	r.mu.Lock()
+	r.encoded = encoded
+	r.mu.Unlock()
+
+	go func() {
+		var buf bytes.Buffer
+		err := encodeGIF(&buf, frames, now)
+		r.mu.Lock()
+		if r.encoded == encoded {
+			r.gif, r.err = buf.Bytes(), err
+		}
+		r.mu.Unlock()
+		close(encoded)
+	}()
+}
+
+// result waits for the last recording to be encoded.
+func (r *recorder) result(ctx context.Context) ([]byte, error) {
+	for {
+		r.mu.Lock()
+		encoded := r.encoded
+		r.mu.Unlock()
+		if encoded == nil {
+			return nil, ErrNoRecording
+		}
+
+		select {
+		case <-encoded:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		r.mu.Lock()
+		if r.encoded == encoded {
+			defer r.mu.Unlock()
+			return r.gif, r.err
+		}
+		// Another recording has been stopped in the meantime.
+		r.mu.Unlock()
+	}
+}
+
+// encodeGIF writes the frames as an animated GIF that loops forever. Every
+// frame is shown until the next one was drawn, and the last one until end.
+func encodeGIF(w io.Writer, frames []recordedFrame, end time.Time) error {
+	if len(frames) == 0 {
+		return fmt.Errorf("recording has no frames")
+	}
+
+	// GIF delays are in hundredths of a second, so frames that would be shown
+	// for less than that are dropped.
+	start := frames[0].at
+	centis := func(t time.Time) int {
+		return int(math.Round(float64(t.Sub(start)) / float64(10*time.Millisecond)))
+	}
+	palette := quantize(frames, 256)
+	anim := &gif.GIF{}
+	for i, f := range frames {
+		next := centis(end)
+		if i+1 < len(frames) {
+			next = centis(frames[i+1].at)
+		}
+		delay := next - centis(f.at)
+		if i+1 == len(frames) {
+			delay = max(delay, 2)
+		}
+		if delay <= 0 {
+			continue
+		}
+
+		bounds := f.img.Bounds()
+		anim.Image = append(anim.Image, paletted(f.img, palette))
+		anim.Delay = append(anim.Delay, delay)
+		anim.Config.Width = max(anim.Config.Width, bounds.Dx())
+		anim.Config.Height = max(anim.Config.Height, bounds.Dy())
+	}
+	anim.Config.ColorModel = palette
+	return gif.EncodeAll(w, anim)
+}
+
+// quantize picks the palette for a recording from an even sample of at most
+// maxPaletteSamples pixels. Colors are grouped with every channel reduced to
+// 5 bits, and the most common groups are represented by their most common
+// color, so flat areas keep their exact color.
+func quantize(frames []recordedFrame, size int) color.Palette {
+	var total int
+	for _, f := range frames {
+		total += len(f.img.Pix) / 4
+	}
+	step := (total + maxPaletteSamples - 1) / maxPaletteSamples
+
+	counts := map[uint32]int{}
+	for n, f := range frames {
+		pix := f.img.Pix
+		// The first sample moves along from frame to frame, so that a step
+		// that divides the width does not always skip the same columns.
+		for i := n % step * 4; i+3 < len(pix); i += step * 4 {
+			counts[uint32(pix[i])<<24|uint32(pix[i+1])<<16|uint32(pix[i+2])<<8|uint32(pix[i+3])]++
+		}
+	}
+
+	type group struct {
+		count, best int
+		color       uint32
+	}
+	groups := map[uint32]*group{}
+	for c, n := range counts {
+		key := c >> 3 & 0x1f1f1f1f
+		g := groups[key]
+		if g == nil {
+			g = &group{}
+			groups[key] = g
+		}
+		g.count += n
+		if n > g.best || n == g.best && c < g.color {
+			g.best, g.color = n, c
+		}
+	}
+
+	sorted := make([]*group, 0, len(groups))
+	for _, g := range groups {
+		sorted = append(sorted, g)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].count != sorted[j].count {
+			return sorted[i].count > sorted[j].count
+		}
+		return sorted[i].color < sorted[j].color
+	})
+
+	palette := make(color.Palette, 0, size)
+	for _, g := range sorted[:min(size, len(sorted))] {
+		palette = append(palette, color.RGBA{R: uint8(g.color >> 24), G: uint8(g.color >> 16), B: uint8(g.color >> 8), A: uint8(g.color)})
+	}
+	return palette
+}
+
+// paletted maps the frame to the palette, looking every distinct color up
+// only once.
+func paletted(img *image.RGBA, palette color.Palette) *image.Paletted {
+	res := image.NewPaletted(img.Bounds(), palette)
+	indices := map[uint32]uint8{}
+	for i, j := 0, 0; i+3 < len(img.Pix); i, j = i+4, j+1 {
+		p := img.Pix[i : i+4 : i+4]
+		key := uint32(p[0])<<24 | uint32(p[1])<<16 | uint32(p[2])<<8 | uint32(p[3])
+		index, ok := indices[key]
+		if !ok {
+			index = uint8(palette.Index(color.RGBA{R: p[0], G: p[1], B: p[2], A: p[3]}))
+			indices[key] = index
+		}
+		res.Pix[j] = index
+	}
+	return res
+}
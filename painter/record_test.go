@@ -0,0 +1,82 @@
+package painter
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestEncodeGIF(t *testing.T) {
+	start := time.Now()
+	frame := func(c color.Color, at time.Duration) recordedFrame {
+		img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+		draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+		return recordedFrame{img: img, at: start.Add(at)}
+	}
+	frames := []recordedFrame{
+		frame(color.White, 0),
+		frame(color.Black, 100*time.Millisecond),
+		// Replaced before it could be shown for a hundredth of a second.
+		frame(color.RGBA{G: 0xff, A: 0xff}, 300*time.Millisecond),
+		frame(color.RGBA{R: 0xff, A: 0xff}, 302*time.Millisecond),
+	}
+
+	var buf bytes.Buffer
+	if err := encodeGIF(&buf, frames, start.Add(time.Second)); err != nil {
+		t.Fatalf("encodeGIF failed: %s", err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("Failed to decode the GIF: %s", err)
+	}
+
+	if want := []int{10, 20, 70}; !slices.Equal(anim.Delay, want) {
+		t.Errorf("Expected delays %v, got %v", want, anim.Delay)
+	}
+	if anim.Config.Width != 10 || anim.Config.Height != 10 {
+		t.Errorf("Expected a 10x10 GIF, got %dx%d", anim.Config.Width, anim.Config.Height)
+	}
+	for i, want := range []color.Color{color.White, color.Black, color.RGBA{R: 0xff, A: 0xff}} {
+		if got := anim.Image[i].At(5, 5); !sameColor(got, want) {
+			t.Errorf("Frame %d: expected %v, got %v", i, want, got)
+		}
+	}
+
+	if err := encodeGIF(&buf, nil, start); err == nil {
+		t.Error("Expected an error for a recording without frames")
+	}
+}
+
+func sameColor(a, b color.Color) bool {
+	r1, g1, b1, a1 := a.RGBA()
+	r2, g2, b2, a2 := b.RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+}
+
+func TestQuantize_SamplesLargeRecordings(t *testing.T) {
+	stripe := color.RGBA{R: 0xff, A: 0xff}
+	var frames []recordedFrame
+	for range 8 {
+		img := image.NewRGBA(image.Rect(0, 0, 512, 512))
+		draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+		// Twice maxPaletteSamples pixels are sampled at every other pixel,
+		// which would always skip this odd column without moving along.
+		draw.Draw(img, image.Rect(1, 0, 2, 512), image.NewUniform(stripe), image.Point{}, draw.Src)
+		frames = append(frames, recordedFrame{img: img})
+	}
+
+	palette := quantize(frames, 256)
+	if len(palette) != 2 {
+		t.Fatalf("Expected the background and the stripe, got %v", palette)
+	}
+	for _, want := range []color.Color{color.White, stripe} {
+		if !sameColor(palette.Convert(want), want) {
+			t.Errorf("Palette %v lacks %v", palette, want)
+		}
+	}
+}
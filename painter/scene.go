@@ -0,0 +1,520 @@
+package painter
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+const SceneVersion = 9
+
+type sceneDocument struct {
+	Version      int            `json:"version"`
+	Background   sceneColor     `json:"background"`
+	Gradient     *sceneGradient `json:"gradient,omitempty"`
+	Layers       []sceneLayer   `json:"layers"`
+	CurrentLayer string         `json:"currentLayer,omitempty"`
+	Rects        []sceneRect    `json:"rects"`
+	Figures      []sceneFigure  `json:"figures"`
+	Paths        []scenePath    `json:"paths,omitempty"`
+	Texts        []sceneText    `json:"texts,omitempty"`
+	Sprites      []sceneSprite  `json:"sprites,omitempty"`
+
+	// BgRect is the single black rectangle of version 1 documents.
+	BgRect *Rect `json:"bgRect,omitempty"`
+}
+
+type sceneLayer struct {
+	Name    string  `json:"name"`
+	Hidden  bool    `json:"hidden,omitempty"`
+	Opacity float64 `json:"opacity"`
+}
+
+type sceneRect struct {
+	ID       string         `json:"id"`
+	Min      Vec            `json:"min"`
+	Max      Vec            `json:"max"`
+	Color    sceneColor     `json:"color"`
+	Gradient *sceneGradient `json:"gradient,omitempty"`
+	Blend    BlendMode      `json:"blend,omitempty"`
+	Layer    string         `json:"layer,omitempty"`
+}
+
+type sceneFigure struct {
+	ID       string     `json:"id"`
+	Center   Vec        `json:"center"`
+	Shape    string     `json:"shape,omitempty"`
+	Color    sceneColor `json:"color"`
+	Size     float64    `json:"size"`
+	Alpha    float64    `json:"alpha"`
+	Rotation float64    `json:"rotation,omitempty"`
+	Blend    BlendMode  `json:"blend,omitempty"`
+	Hidden   bool       `json:"hidden,omitempty"`
+	Layer    string     `json:"layer,omitempty"`
+
+	Velocity     *Vec     `json:"velocity,omitempty"`
+	Acceleration *Vec     `json:"acceleration,omitempty"`
+	Boundary     Boundary `json:"boundary,omitempty"`
+}
+
+type scenePath struct {
+	ID     string     `json:"id"`
+	Kind   PathKind   `json:"kind"`
+	Points []Vec      `json:"points"`
+	Color  sceneColor `json:"color"`
+	Width  float64    `json:"width"`
+	Dash   []float64  `json:"dash,omitempty"`
+	Cap    LineCap    `json:"cap,omitempty"`
+	Join   LineJoin   `json:"join,omitempty"`
+	Layer  string     `json:"layer,omitempty"`
+}
+
+type sceneText struct {
+	ID       string     `json:"id"`
+	Content  string     `json:"content"`
+	Position Vec        `json:"position"`
+	Size     float64    `json:"size"`
+	Color    sceneColor `json:"color"`
+	Align    TextAlign  `json:"align,omitempty"`
+	Figure   string     `json:"figure,omitempty"`
+	Layer    string     `json:"layer,omitempty"`
+}
+
+// sceneSprite refers to an asset by name; the image itself is not embedded.
+type sceneSprite struct {
+	ID       string `json:"id"`
+	Asset    string `json:"asset"`
+	Position Vec    `json:"position"`
+	Size     Vec    `json:"size"`
+	Layer    string `json:"layer,omitempty"`
+}
+
+type sceneGradient struct {
+	Kind   GradientKind `json:"kind"`
+	Angle  float64      `json:"angle,omitempty"`
+	Center Vec          `json:"center"`
+	Radius float64      `json:"radius,omitempty"`
+	Stops  []sceneStop  `json:"stops"`
+}
+
+type sceneStop struct {
+	Offset float64    `json:"offset"`
+	Color  sceneColor `json:"color"`
+}
+
+func newSceneGradient(g *Gradient) *sceneGradient {
+	if g == nil {
+		return nil
+	}
+	sg := &sceneGradient{Kind: g.Kind, Angle: g.Angle, Center: g.Center, Radius: g.Radius}
+	for _, stop := range g.Stops {
+		sg.Stops = append(sg.Stops, sceneStop{Offset: stop.Offset, Color: sceneColor{stop.Color}})
+	}
+	return sg
+}
+
+func (sg *sceneGradient) gradient() (*Gradient, error) {
+	if sg == nil {
+		return nil, nil
+	}
+	g := &Gradient{Kind: sg.Kind, Angle: sg.Angle, Center: sg.Center, Radius: sg.Radius}
+	for _, stop := range sg.Stops {
+		g.Stops = append(g.Stops, GradientStop{Offset: stop.Offset, Color: stop.Color.Color})
+	}
+	if err := ValidateGradient(g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// sceneColor is encoded as a "#rrggbbaa" string with non-premultiplied alpha.
+type sceneColor struct {
+	color.Color
+}
+
+func (c sceneColor) MarshalJSON() ([]byte, error) {
+	if c.Color == nil {
+		return json.Marshal("#00000000")
+	}
+	n := color.NRGBAModel.Convert(c.Color).(color.NRGBA)
+	return json.Marshal(fmt.Sprintf("#%02x%02x%02x%02x", n.R, n.G, n.B, n.A))
+}
+
+func (c *sceneColor) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if len(s) != 9 || s[0] != '#' {
+		return fmt.Errorf("color must be in #rrggbbaa form: %q", s)
+	}
+	b, err := hex.DecodeString(s[1:])
+	if err != nil {
+		return fmt.Errorf("invalid color %q: %w", s, err)
+	}
+	c.Color = color.NRGBA{R: b[0], G: b[1], B: b[2], A: b[3]}
+	return nil
+}
+
+func MarshalScene(s *State) ([]byte, error) {
+	doc := sceneDocument{
+		Version:      SceneVersion,
+		Background:   sceneColor{s.BackgroundColor},
+		Gradient:     newSceneGradient(s.BackgroundGradient),
+		Layers:       []sceneLayer{},
+		CurrentLayer: s.CurrentLayer,
+		Rects:        []sceneRect{},
+		Figures:      []sceneFigure{},
+	}
+	for _, l := range s.Layers {
+		doc.Layers = append(doc.Layers, sceneLayer{Name: l.Name, Hidden: l.Hidden, Opacity: l.Opacity})
+	}
+	for _, r := range s.BgRects {
+		doc.Rects = append(doc.Rects, sceneRect{ID: r.ID, Min: r.Min, Max: r.Max, Color: sceneColor{r.Color}, Gradient: newSceneGradient(r.Gradient), Blend: r.Blend, Layer: r.Layer})
+	}
+	for _, f := range s.Figures {
+		sf := sceneFigure{
+			ID:       f.ID,
+			Center:   f.Center,
+			Shape:    f.Shape,
+			Color:    sceneColor{f.Color},
+			Size:     f.Size,
+			Alpha:    f.Alpha,
+			Rotation: f.Rotation,
+			Blend:    f.Blend,
+			Hidden:   f.Hidden,
+			Layer:    f.Layer,
+			Boundary: f.Boundary,
+		}
+		if f.Velocity != (Vec{}) {
+			sf.Velocity = &f.Velocity
+		}
+		if f.Acceleration != (Vec{}) {
+			sf.Acceleration = &f.Acceleration
+		}
+		doc.Figures = append(doc.Figures, sf)
+	}
+	for _, p := range s.Paths {
+		doc.Paths = append(doc.Paths, scenePath{
+			ID:     p.ID,
+			Kind:   p.Kind,
+			Points: p.Points,
+			Color:  sceneColor{p.Color},
+			Width:  p.Width,
+			Dash:   p.Dash,
+			Cap:    p.Cap,
+			Join:   p.Join,
+			Layer:  p.Layer,
+		})
+	}
+	for _, t := range s.Texts {
+		doc.Texts = append(doc.Texts, sceneText{
+			ID:       t.ID,
+			Content:  t.Content,
+			Position: t.Position,
+			Size:     t.Size,
+			Color:    sceneColor{t.Color},
+			Align:    t.Align,
+			Figure:   t.Figure,
+			Layer:    t.Layer,
+		})
+	}
+	for _, sp := range s.Sprites {
+		doc.Sprites = append(doc.Sprites, sceneSprite{ID: sp.ID, Asset: sp.Asset, Position: sp.Position, Size: sp.Size, Layer: sp.Layer})
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func UnmarshalScene(data []byte) (*State, error) {
+	var doc sceneDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid scene: %w", err)
+	}
+	if doc.Version < 1 || doc.Version > SceneVersion {
+		return nil, fmt.Errorf("unsupported scene version %d", doc.Version)
+	}
+	if doc.Version == 1 && doc.BgRect != nil {
+		doc.Rects = []sceneRect{{ID: "r1", Min: doc.BgRect.Min, Max: doc.BgRect.Max, Color: sceneColor{color.Black}}}
+	}
+	if doc.Background.Color == nil {
+		return nil, fmt.Errorf("scene has no background color")
+	}
+
+	s := DefaultState()
+	s.BackgroundColor = doc.Background.Color
+	gradient, err := doc.Gradient.gradient()
+	if err != nil {
+		return nil, fmt.Errorf("background: %w", err)
+	}
+	s.BackgroundGradient = gradient
+	if len(doc.Layers) > 0 {
+		s.Layers = nil
+	}
+	for _, sl := range doc.Layers {
+		if sl.Name == "" || s.layerIndex(sl.Name) >= 0 {
+			return nil, fmt.Errorf("scene layer names must be unique and non-empty")
+		}
+		if sl.Opacity < 0 || sl.Opacity > 1 {
+			return nil, fmt.Errorf("layer %s: opacity must be within [0, 1]", sl.Name)
+		}
+		s.Layers = append(s.Layers, Layer{Name: sl.Name, Hidden: sl.Hidden, Opacity: sl.Opacity})
+	}
+	layerOf := func(name string) (string, error) {
+		if name == "" {
+			name = DefaultLayer
+		}
+		if s.layerIndex(name) < 0 {
+			return "", fmt.Errorf("unknown layer %q", name)
+		}
+		return name, nil
+	}
+	if doc.CurrentLayer != "" {
+		if _, err := layerOf(doc.CurrentLayer); err != nil {
+			return nil, err
+		}
+		s.CurrentLayer = doc.CurrentLayer
+	}
+	for _, sr := range doc.Rects {
+		if !ValidID(sr.ID) || s.bgRectIndex(sr.ID) >= 0 {
+			return nil, fmt.Errorf("scene rect IDs must be unique and valid: %q", sr.ID)
+		}
+		if sr.Color.Color == nil {
+			return nil, fmt.Errorf("rect %s has no color", sr.ID)
+		}
+		layer, err := layerOf(sr.Layer)
+		if err != nil {
+			return nil, fmt.Errorf("rect %s: %w", sr.ID, err)
+		}
+		gradient, err := sr.Gradient.gradient()
+		if err != nil {
+			return nil, fmt.Errorf("rect %s: %w", sr.ID, err)
+		}
+		if !ValidBlendMode(sr.Blend) {
+			return nil, fmt.Errorf("rect %s: unknown blend mode %q", sr.ID, sr.Blend)
+		}
+		s.BgRects = append(s.BgRects, BgRect{ID: sr.ID, Rect: Rect{Min: sr.Min, Max: sr.Max}, Color: sr.Color.Color, Gradient: gradient, Blend: sr.Blend, Layer: layer})
+	}
+	for _, sf := range doc.Figures {
+		if !ValidID(sf.ID) || s.Figure(sf.ID) != nil {
+			return nil, fmt.Errorf("scene figure IDs must be unique and valid: %q", sf.ID)
+		}
+		if sf.Color.Color == nil {
+			return nil, fmt.Errorf("figure %s has no color", sf.ID)
+		}
+		if sf.Size <= 0 {
+			return nil, fmt.Errorf("figure %s: size must be greater than 0", sf.ID)
+		}
+		if sf.Alpha < 0 || sf.Alpha > 1 {
+			return nil, fmt.Errorf("figure %s: alpha must be within [0, 1]", sf.ID)
+		}
+		if sf.Shape != "" {
+			if _, err := ParseShape(sf.Shape); err != nil {
+				return nil, fmt.Errorf("figure %s: %w", sf.ID, err)
+			}
+		}
+		if !ValidBlendMode(sf.Blend) {
+			return nil, fmt.Errorf("figure %s: unknown blend mode %q", sf.ID, sf.Blend)
+		}
+		if !ValidBoundary(sf.Boundary) {
+			return nil, fmt.Errorf("figure %s: unknown boundary %q", sf.ID, sf.Boundary)
+		}
+		layer, err := layerOf(sf.Layer)
+		if err != nil {
+			return nil, fmt.Errorf("figure %s: %w", sf.ID, err)
+		}
+		figure := Figure{
+			ID:       sf.ID,
+			Center:   sf.Center,
+			Shape:    sf.Shape,
+			Color:    sf.Color.Color,
+			Size:     sf.Size,
+			Alpha:    sf.Alpha,
+			Rotation: sf.Rotation,
+			Blend:    sf.Blend,
+			Hidden:   sf.Hidden,
+			Layer:    layer,
+			Boundary: sf.Boundary,
+		}
+		if sf.Velocity != nil {
+			figure.Velocity = *sf.Velocity
+		}
+		if sf.Acceleration != nil {
+			figure.Acceleration = *sf.Acceleration
+		}
+		s.Figures = append(s.Figures, figure)
+	}
+	for _, sp := range doc.Paths {
+		if !ValidID(sp.ID) || s.pathIndex(sp.ID) >= 0 {
+			return nil, fmt.Errorf("scene path IDs must be unique and valid: %q", sp.ID)
+		}
+		if sp.Color.Color == nil {
+			return nil, fmt.Errorf("path %s has no color", sp.ID)
+		}
+		stroke := Stroke{Width: sp.Width, Color: sp.Color.Color, Dash: sp.Dash, Cap: sp.Cap, Join: sp.Join}
+		if err := ValidatePath(sp.Kind, len(sp.Points)); err != nil {
+			return nil, fmt.Errorf("path %s: %w", sp.ID, err)
+		}
+		if err := ValidateStroke(stroke); err != nil {
+			return nil, fmt.Errorf("path %s: %w", sp.ID, err)
+		}
+		layer, err := layerOf(sp.Layer)
+		if err != nil {
+			return nil, fmt.Errorf("path %s: %w", sp.ID, err)
+		}
+		s.Paths = append(s.Paths, Path{ID: sp.ID, Kind: sp.Kind, Points: sp.Points, Stroke: stroke, Layer: layer})
+	}
+	for _, st := range doc.Texts {
+		if !ValidID(st.ID) || s.textIndex(st.ID) >= 0 {
+			return nil, fmt.Errorf("scene text IDs must be unique and valid: %q", st.ID)
+		}
+		if st.Color.Color == nil {
+			return nil, fmt.Errorf("text %s has no color", st.ID)
+		}
+		if !ValidTextSize(st.Size) {
+			return nil, fmt.Errorf("text %s: size must be within (0, %g]", st.ID, MaxTextSize)
+		}
+		if !ValidTextAlign(st.Align) {
+			return nil, fmt.Errorf("text %s: unknown alignment %q", st.ID, st.Align)
+		}
+		layer, err := layerOf(st.Layer)
+		if err != nil {
+			return nil, fmt.Errorf("text %s: %w", st.ID, err)
+		}
+		s.Texts = append(s.Texts, Text{
+			ID:       st.ID,
+			Content:  st.Content,
+			Position: st.Position,
+			Size:     st.Size,
+			Color:    st.Color.Color,
+			Align:    st.Align,
+			Figure:   st.Figure,
+			Layer:    layer,
+		})
+	}
+	for _, ss := range doc.Sprites {
+		if !ValidID(ss.ID) || s.spriteIndex(ss.ID) >= 0 {
+			return nil, fmt.Errorf("scene sprite IDs must be unique and valid: %q", ss.ID)
+		}
+		if !ValidAssetName(ss.Asset) {
+			return nil, fmt.Errorf("sprite %s: invalid asset name %q", ss.ID, ss.Asset)
+		}
+		if ss.Size.X < 0 || ss.Size.Y < 0 {
+			return nil, fmt.Errorf("sprite %s: size must not be negative", ss.ID)
+		}
+		layer, err := layerOf(ss.Layer)
+		if err != nil {
+			return nil, fmt.Errorf("sprite %s: %w", ss.ID, err)
+		}
+		s.Sprites = append(s.Sprites, Sprite{ID: ss.ID, Asset: ss.Asset, Position: ss.Position, Size: ss.Size, Layer: layer})
+	}
+	return s, nil
+}
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func ValidSceneName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// ValidID reports whether id can name a figure, rect, path, text or sprite.
+// The names collision handlers use for the canvas edges and for any target
+// are reserved.
+func ValidID(id string) bool {
+	return namePattern.MatchString(id) && id != CollideEdge && id != CollideAny
+}
+
+// SceneStore keeps scenes as <name>.json files in Dir.
+type SceneStore struct {
+	Dir string
+}
+
+func (st *SceneStore) path(name string) (string, error) {
+	if !ValidSceneName(name) {
+		return "", fmt.Errorf("invalid scene name %q", name)
+	}
+	return filepath.Join(st.Dir, name+".json"), nil
+}
+
+func (st *SceneStore) Read(name string) ([]byte, error) {
+	path, err := st.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// Write stores raw scene data as is; callers are expected to validate it
+// with UnmarshalScene first.
+func (st *SceneStore) Write(name string, data []byte) error {
+	path, err := st.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(st.Dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (st *SceneStore) Save(name string, s *State) error {
+	data, err := MarshalScene(s)
+	if err != nil {
+		return err
+	}
+	return st.Write(name, data)
+}
+
+func (st *SceneStore) Load(name string) (*State, error) {
+	data, err := st.Read(name)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalScene(data)
+}
+
+type SaveOp struct {
+	Store *SceneStore
+	Name  string
+}
+
+func (op SaveOp) Do(t screen.Texture, s *State) bool {
+	if err := op.Run(s); err != nil {
+		log.Print(err)
+	}
+	return false
+}
+
+func (op SaveOp) Run(s *State) error {
+	if err := op.Store.Save(op.Name, s); err != nil {
+		return fmt.Errorf("failed to save scene %s: %w", op.Name, err)
+	}
+	return nil
+}
+
+type LoadOp struct {
+	Store *SceneStore
+	Name  string
+}
+
+func (op LoadOp) Do(t screen.Texture, s *State) bool {
+	if err := op.Run(s); err != nil {
+		log.Print(err)
+	}
+	return false
+}
+
+func (op LoadOp) Run(s *State) error {
+	loaded, err := op.Store.Load(op.Name)
+	if err != nil {
+		return fmt.Errorf("failed to load scene %s: %w", op.Name, err)
+	}
+	*s = *loaded
+	return nil
+}
@@ -0,0 +1,136 @@
+package painter
+
+import (
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/maxnetyaga/software-architecture-lab3/ui/headless"
+)
+
+func TestScene_RoundTrip(t *testing.T) {
+	s := DefaultState()
+	s.BackgroundColor = color.NRGBA{R: 0x12, G: 0x34, B: 0x56, A: 0xff}
+	s.BgRects = []BgRect{
+		{ID: "r1", Rect: Rect{Min: Vec{X: 0.25, Y: 0.25}, Max: Vec{X: 0.75, Y: 0.5}}, Color: color.NRGBA{A: 0xff}, Layer: DefaultLayer},
+		{ID: "overlay", Rect: Rect{Min: Vec{X: 0, Y: 0}, Max: Vec{X: 1, Y: 0.125}}, Color: color.NRGBA{R: 0xff, A: 0x40}, Blend: BlendMultiply, Layer: "hud"},
+	}
+	s.BackgroundGradient = NewRadialGradient(Vec{X: 0.5, Y: 0.25}, 0.75, []GradientStop{
+		{Offset: 0, Color: color.NRGBA{R: 0xff, A: 0xff}},
+		{Offset: 1, Color: color.NRGBA{B: 0xff, A: 0x80}},
+	})
+	s.BgRects[0].Gradient = NewLinearGradient(45, []GradientStop{
+		{Offset: 0, Color: color.NRGBA{A: 0xff}},
+		{Offset: 0.5, Color: color.NRGBA{G: 0xff, A: 0xff}},
+		{Offset: 1, Color: color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}},
+	})
+	s.Layers = append(s.Layers, Layer{Name: "hud", Hidden: true, Opacity: 0.5})
+	s.CurrentLayer = "hud"
+	star := NewFigure("star", Vec{X: 0.5, Y: 0.5})
+	star.Shape, star.Color, star.Size, star.Alpha = "star:6,0.4", color.NRGBA{R: 0xff, A: 0x80}, 0.1, 0.5
+	star.Rotation, star.Blend = 30, BlendScreen
+	star.Velocity, star.Acceleration, star.Boundary = Vec{X: 0.1, Y: -0.2}, Vec{Y: 0.98}, BoundaryBounce
+	hidden := NewFigure("hidden", Vec{X: 0.125, Y: 0.875})
+	hidden.Color, hidden.Hidden = color.NRGBA{G: 0xff, A: 0xff}, true
+	s.Figures = []Figure{star, hidden}
+	s.Paths = []Path{{
+		ID:     "curve",
+		Kind:   PathCubic,
+		Points: []Vec{{X: 0, Y: 0}, {X: 0.25, Y: 1}, {X: 0.75, Y: 0}, {X: 1, Y: 1}},
+		Stroke: Stroke{Width: 0.01, Color: color.NRGBA{B: 0xff, A: 0xff}, Dash: []float64{0.02, 0.01}, Cap: CapRound, Join: JoinBevel},
+		Layer:  "hud",
+	}}
+	s.Texts = []Text{{
+		ID:       "label",
+		Content:  "Star \"1\"",
+		Position: Vec{X: 0, Y: 0.1},
+		Size:     0.03,
+		Color:    color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		Align:    AlignCenter,
+		Figure:   "star",
+		Layer:    DefaultLayer,
+	}}
+	s.Sprites = []Sprite{{ID: "logo", Asset: "logo", Position: Vec{X: 0.875, Y: 0}, Size: Vec{X: 0.125, Y: 0.125}, Layer: "hud"}}
+
+	data, err := MarshalScene(s)
+	if err != nil {
+		t.Fatalf("MarshalScene failed: %s", err)
+	}
+	if !strings.Contains(string(data), `"version": 9`) {
+		t.Errorf("Scene document is not versioned: %s", data)
+	}
+
+	loaded, err := UnmarshalScene(data)
+	if err != nil {
+		t.Fatalf("UnmarshalScene failed: %s", err)
+	}
+	if !reflect.DeepEqual(loaded, s) {
+		t.Errorf("Scene did not survive a round trip.\nExpected: %+v\nGot: %+v", s, loaded)
+	}
+}
+
+func TestScene_Invalid(t *testing.T) {
+	for name, doc := range map[string]string{
+		"malformed json":     `{"version": 1`,
+		"unknown version":    `{"version": 99, "background": "#000000ff", "figures": []}`,
+		"missing color":      `{"version": 2, "figures": []}`,
+		"short color":        `{"version": 2, "background": "#000", "figures": []}`,
+		"duplicate figures":  `{"version": 2, "background": "#000000ff", "figures": [{"id": "a", "color": "#ffffffff", "size": 0.1}, {"id": "a", "color": "#ffffffff", "size": 0.1}]}`,
+		"duplicate rects":    `{"version": 2, "background": "#000000ff", "rects": [{"id": "a", "color": "#ffffffff"}, {"id": "a", "color": "#ffffffff"}]}`,
+		"unknown layer":      `{"version": 3, "background": "#000000ff", "figures": [{"id": "a", "color": "#ffffffff", "size": 0.1, "layer": "hud"}]}`,
+		"bad path":           `{"version": 4, "background": "#000000ff", "paths": [{"id": "a", "kind": "quad", "points": [{"x": 0, "y": 0}], "color": "#ffffffff"}]}`,
+		"bad gradient":       `{"version": 7, "background": "#000000ff", "gradient": {"kind": "linear", "stops": [{"offset": 0, "color": "#ffffffff"}]}}`,
+		"unknown blend":      `{"version": 8, "background": "#000000ff", "figures": [{"id": "a", "color": "#ffffffff", "size": 0.1, "blend": "burn"}]}`,
+		"unknown boundary":   `{"version": 9, "background": "#000000ff", "figures": [{"id": "a", "color": "#ffffffff", "size": 0.1, "boundary": "teleport"}]}`,
+		"reserved figure id": `{"version": 9, "background": "#000000ff", "figures": [{"id": "edge", "color": "#ffffffff", "size": 0.1}]}`,
+		"invalid rect id":    `{"version": 9, "background": "#000000ff", "rects": [{"id": "a,b", "color": "#ffffffff"}]}`,
+		"huge text":          `{"version": 9, "background": "#000000ff", "texts": [{"id": "a", "content": "WWWW", "size": 200, "color": "#ffffffff"}]}`,
+		"zero figure size":   `{"version": 9, "background": "#000000ff", "figures": [{"id": "a", "color": "#ffffffff"}]}`,
+		"figure alpha":       `{"version": 9, "background": "#000000ff", "figures": [{"id": "a", "color": "#ffffffff", "size": 0.1, "alpha": 2}]}`,
+		"layer opacity":      `{"version": 9, "background": "#000000ff", "layers": [{"name": "default", "opacity": 1.5}]}`,
+		"negative sprite":    `{"version": 9, "background": "#000000ff", "sprites": [{"id": "a", "asset": "logo", "size": {"x": -1, "y": 1}}]}`,
+		"unknown shape":      `{"version": 2, "background": "#000000ff", "figures": [{"id": "a", "shape": "blob", "color": "#ffffffff", "size": 0.1}]}`,
+	} {
+		if _, err := UnmarshalScene([]byte(doc)); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+	}
+}
+
+func TestScene_Version1(t *testing.T) {
+	doc := `{"version": 1, "background": "#ffffffff", "bgRect": {"min": {"x": 0.25, "y": 0.25}, "max": {"x": 0.75, "y": 0.75}}, "figures": []}`
+
+	s, err := UnmarshalScene([]byte(doc))
+	if err != nil {
+		t.Fatalf("UnmarshalScene failed for a version 1 scene: %s", err)
+	}
+	expected := []BgRect{{ID: "r1", Rect: Rect{Min: Vec{X: 0.25, Y: 0.25}, Max: Vec{X: 0.75, Y: 0.75}}, Color: color.Black, Layer: DefaultLayer}}
+	if !reflect.DeepEqual(s.BgRects, expected) {
+		t.Errorf("Expected the version 1 rect to become %v, got %v", expected, s.BgRects)
+	}
+}
+
+func TestSceneStore(t *testing.T) {
+	store := &SceneStore{Dir: t.TempDir()}
+	s := DefaultState()
+	s.BackgroundColor = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	s.Figures = []Figure{NewFigure("a", Vec{X: 0.5, Y: 0.5})}
+	s.Figures[0].Color = color.NRGBA{R: 0xff, G: 0xff, A: 0xff}
+
+	texture := &headless.Texture{}
+	SaveOp{Store: store, Name: "demo"}.Do(texture, s)
+
+	loaded := DefaultState()
+	LoadOp{Store: store, Name: "demo"}.Do(texture, loaded)
+	if !reflect.DeepEqual(loaded, s) {
+		t.Errorf("Loaded scene differs from saved one.\nExpected: %+v\nGot: %+v", s, loaded)
+	}
+
+	if err := store.Save("../escape", s); err == nil {
+		t.Error("Expected an error for a scene name with a path")
+	}
+	if _, err := store.Load("missing"); err == nil {
+		t.Error("Expected an error when loading a missing scene")
+	}
+}
@@ -0,0 +1,186 @@
+package painter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const DefaultShape = "cross"
+
+type Vec struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Shape describes an outline of unit size centered at the origin, so every
+// vertex lies within [-0.5, 0.5] on both axes.
+type Shape interface {
+	Polygons() [][]Vec
+}
+
+type Polygons [][]Vec
+
+func (p Polygons) Polygons() [][]Vec {
+	return p
+}
+
+type ShapeFunc func(args []float64) (Shape, error)
+
+var (
+	shapesMu sync.RWMutex
+	shapes   = map[string]ShapeFunc{
+		"cross":     crossShape,
+		"circle":    circleShape,
+		"rectangle": rectangleShape,
+		"triangle":  triangleShape,
+		"star":      starShape,
+		"ngon":      ngonShape,
+		"polygon":   polygonShape,
+	}
+)
+
+func RegisterShape(name string, f ShapeFunc) {
+	shapesMu.Lock()
+	defer shapesMu.Unlock()
+	shapes[name] = f
+}
+
+// ParseShape resolves a shape spec of the form "name" or "name:arg1,arg2,..."
+// through the registry.
+func ParseShape(spec string) (Shape, error) {
+	name, rawArgs, _ := strings.Cut(spec, ":")
+
+	shapesMu.RLock()
+	f, ok := shapes[name]
+	shapesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown shape: %s", name)
+	}
+
+	var args []float64
+	if rawArgs != "" {
+		for _, raw := range strings.Split(rawArgs, ",") {
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid argument for shape %s: %w", name, err)
+			}
+			args = append(args, v)
+		}
+	}
+	return f(args)
+}
+
+func crossShape(args []float64) (Shape, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("cross shape takes no arguments")
+	}
+	const arm = 1.0 / 6
+	return Polygons{
+		rectPolygon(-0.5, -arm, 0.5, arm),
+		rectPolygon(-arm, -0.5, arm, 0.5),
+	}, nil
+}
+
+func circleShape(args []float64) (Shape, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("circle shape takes no arguments")
+	}
+	return Polygons{regularPolygon(64, 0.5, 0)}, nil
+}
+
+func rectangleShape(args []float64) (Shape, error) {
+	w, h := 1.0, 1.0
+	switch len(args) {
+	case 0:
+	case 2:
+		w, h = args[0], args[1]
+	default:
+		return nil, fmt.Errorf("rectangle shape takes either no arguments or width and height")
+	}
+	if w <= 0 || h <= 0 || w > 1 || h > 1 {
+		return nil, fmt.Errorf("rectangle sides must be in (0, 1]")
+	}
+	return Polygons{rectPolygon(-w/2, -h/2, w/2, h/2)}, nil
+}
+
+func triangleShape(args []float64) (Shape, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("triangle shape takes no arguments")
+	}
+	return Polygons{regularPolygon(3, 0.5, -math.Pi/2)}, nil
+}
+
+func starShape(args []float64) (Shape, error) {
+	points, inner := 5, 0.5
+	if len(args) > 2 {
+		return nil, fmt.Errorf("star shape takes at most 2 arguments")
+	}
+	if len(args) > 0 {
+		if !validSides(args[0]) {
+			return nil, fmt.Errorf("star needs a whole number of points from 3 to %d", maxSides)
+		}
+		points = int(args[0])
+	}
+	if len(args) > 1 {
+		inner = args[1]
+	}
+	if inner <= 0 || inner >= 1 {
+		return nil, fmt.Errorf("star needs an inner ratio in (0, 1)")
+	}
+
+	vertices := make([]Vec, 0, points*2)
+	for i := 0; i < points*2; i++ {
+		r := 0.5
+		if i%2 == 1 {
+			r *= inner
+		}
+		angle := -math.Pi/2 + float64(i)*math.Pi/float64(points)
+		vertices = append(vertices, Vec{X: r * math.Cos(angle), Y: r * math.Sin(angle)})
+	}
+	return Polygons{vertices}, nil
+}
+
+// maxSides bounds the vertices of stars and n-gons, which are rebuilt for
+// every frame.
+const maxSides = 64
+
+func validSides(v float64) bool {
+	return v == math.Trunc(v) && v >= 3 && v <= maxSides
+}
+
+func ngonShape(args []float64) (Shape, error) {
+	if len(args) != 1 || !validSides(args[0]) {
+		return nil, fmt.Errorf("ngon shape requires a whole side count from 3 to %d", maxSides)
+	}
+	return Polygons{regularPolygon(int(args[0]), 0.5, -math.Pi/2)}, nil
+}
+
+func polygonShape(args []float64) (Shape, error) {
+	if len(args) < 6 || len(args)%2 != 0 {
+		return nil, fmt.Errorf("polygon shape requires at least 3 x,y pairs")
+	}
+	vertices := make([]Vec, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		if math.Abs(args[i]) > 0.5 || math.Abs(args[i+1]) > 0.5 {
+			return nil, fmt.Errorf("polygon vertices must lie within [-0.5, 0.5]")
+		}
+		vertices = append(vertices, Vec{X: args[i], Y: args[i+1]})
+	}
+	return Polygons{vertices}, nil
+}
+
+func rectPolygon(x1, y1, x2, y2 float64) []Vec {
+	return []Vec{{x1, y1}, {x2, y1}, {x2, y2}, {x1, y2}}
+}
+
+func regularPolygon(sides int, r, phase float64) []Vec {
+	vertices := make([]Vec, sides)
+	for i := range vertices {
+		angle := phase + 2*math.Pi*float64(i)/float64(sides)
+		vertices[i] = Vec{X: r * math.Cos(angle), Y: r * math.Sin(angle)}
+	}
+	return vertices
+}
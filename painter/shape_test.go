@@ -0,0 +1,78 @@
+package painter
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/maxnetyaga/software-architecture-lab3/ui/headless"
+)
+
+func TestParseShape(t *testing.T) {
+	tests := []struct {
+		spec        string
+		polygons    int
+		vertices    int
+		expectError bool
+	}{
+		{spec: "cross", polygons: 2, vertices: 4},
+		{spec: "circle", polygons: 1, vertices: 64},
+		{spec: "rectangle", polygons: 1, vertices: 4},
+		{spec: "rectangle:1,0.5", polygons: 1, vertices: 4},
+		{spec: "triangle", polygons: 1, vertices: 3},
+		{spec: "star", polygons: 1, vertices: 10},
+		{spec: "star:7,0.3", polygons: 1, vertices: 14},
+		{spec: "ngon:6", polygons: 1, vertices: 6},
+		{spec: "polygon:-0.5,-0.5,0.5,-0.5,0,0.5", polygons: 1, vertices: 3},
+		{spec: "hexagon", expectError: true},
+		{spec: "cross:1", expectError: true},
+		{spec: "ngon", expectError: true},
+		{spec: "ngon:x", expectError: true},
+		{spec: "ngon:64", polygons: 1, vertices: 64},
+		{spec: "ngon:65", expectError: true},
+		{spec: "ngon:1e300", expectError: true},
+		{spec: "ngon:6.5", expectError: true},
+		{spec: "ngon:NaN", expectError: true},
+		{spec: "star:1e8", expectError: true},
+		{spec: "star:5.5", expectError: true},
+		{spec: "rectangle:2,1", expectError: true},
+		{spec: "polygon:0,0,1,1", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			shape, err := ParseShape(tt.spec)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("Expected error: %v, Got error: %v", tt.expectError, err)
+			}
+			if err != nil {
+				return
+			}
+			polygons := shape.Polygons()
+			if len(polygons) != tt.polygons {
+				t.Fatalf("Expected %d polygons, got %d", tt.polygons, len(polygons))
+			}
+			if len(polygons[0]) != tt.vertices {
+				t.Errorf("Expected %d vertices, got %d", tt.vertices, len(polygons[0]))
+			}
+		})
+	}
+}
+
+func TestRegisterShape(t *testing.T) {
+	RegisterShape("test-diamond", func(args []float64) (Shape, error) {
+		return Polygons{{{0, -0.5}, {0.5, 0}, {0, 0.5}, {-0.5, 0}}}, nil
+	})
+
+	shape, err := ParseShape("test-diamond")
+	if err != nil {
+		t.Fatalf("Registered shape was not found: %s", err)
+	}
+
+	texture, _ := headless.Screen{}.NewTexture(image.Pt(100, 100))
+	DrawShape(texture.(*headless.Texture).RGBA(), shape, Vec{X: 50, Y: 50}, 80, color.White)
+
+	checkPixelColor(t, texture, 50, 50, color.White, "Diamond center is not filled")
+	checkPixelColor(t, texture, 80, 50, color.White, "Diamond right vertex area is not filled")
+	checkPixelColor(t, texture, 15, 15, color.Transparent, "Pixel outside diamond is filled")
+}
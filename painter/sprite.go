@@ -0,0 +1,240 @@
+package painter
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/draw"
+)
+
+// MaxAssetPixels bounds the size of a decoded asset, which is checked before
+// the image is decoded.
+const MaxAssetPixels = 4096 * 4096
+
+func ValidAssetName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// DecodeAsset accepts PNG, JPEG and GIF images; only the first frame of an
+// animated GIF is used.
+func DecodeAsset(data []byte) (image.Image, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported image: %w", err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width > MaxAssetPixels/config.Height {
+		return nil, fmt.Errorf("image of %dx%d pixels is too large", config.Width, config.Height)
+	}
+	r := bytes.NewReader(data)
+	switch format {
+	case "png":
+		return png.Decode(r)
+	case "jpeg":
+		return jpeg.Decode(r)
+	case "gif":
+		return gif.Decode(r)
+	default:
+		return nil, fmt.Errorf("unsupported image format: %s", format)
+	}
+}
+
+// AssetStore keeps uploaded images as files in Dir and registers them for
+// drawing.
+type AssetStore struct {
+	Dir string
+
+	mu     sync.RWMutex
+	images map[string]image.Image
+}
+
+// Register makes the image available to sprites under the given name.
+func (st *AssetStore) Register(name string, img image.Image) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.images == nil {
+		st.images = map[string]image.Image{}
+	}
+	st.images[name] = img
+}
+
+// Lookup returns the image registered under name. A nil store has no images.
+func (st *AssetStore) Lookup(name string) (image.Image, bool) {
+	if st == nil {
+		return nil, false
+	}
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	img, ok := st.images[name]
+	return img, ok
+}
+
+func (st *AssetStore) path(name string) (string, error) {
+	if !ValidAssetName(name) {
+		return "", fmt.Errorf("invalid asset name %q", name)
+	}
+	return filepath.Join(st.Dir, name), nil
+}
+
+func (st *AssetStore) Read(name string) ([]byte, error) {
+	path, err := st.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// Write decodes the image, stores it and registers it under name.
+func (st *AssetStore) Write(name string, data []byte) error {
+	path, err := st.path(name)
+	if err != nil {
+		return err
+	}
+	img, err := DecodeAsset(data)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(st.Dir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	st.Register(name, img)
+	return nil
+}
+
+func (st *AssetStore) List() ([]string, error) {
+	entries, err := os.ReadDir(st.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && ValidAssetName(e.Name()) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Load registers every stored asset, so sprites keep working after a restart.
+func (st *AssetStore) Load() error {
+	names, err := st.List()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		data, err := st.Read(name)
+		if err != nil {
+			return err
+		}
+		img, err := DecodeAsset(data)
+		if err != nil {
+			return fmt.Errorf("asset %s: %w", name, err)
+		}
+		st.Register(name, img)
+	}
+	return nil
+}
+
+// Sprite draws a registered asset with its top left corner at Position. A
+// zero Size keeps the natural pixel size of the image.
+type Sprite struct {
+	ID       string
+	Asset    string
+	Position Vec
+	Size     Vec
+	Layer    string
+}
+
+func (s *State) spriteIndex(id string) int {
+	for i := range s.Sprites {
+		if s.Sprites[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *State) newSpriteID() string {
+	for n := len(s.Sprites) + 1; ; n++ {
+		id := fmt.Sprintf("s%d", n)
+		if s.spriteIndex(id) < 0 {
+			return id
+		}
+	}
+}
+
+type SpriteOp struct {
+	ID    string
+	Asset string
+	X, Y  float64
+	W, H  float64
+}
+
+func (op SpriteOp) Do(t screen.Texture, s *State) bool {
+	sprite := Sprite{
+		ID:       op.ID,
+		Asset:    op.Asset,
+		Position: Vec{X: op.X, Y: op.Y},
+		Size:     Vec{X: op.W, Y: op.H},
+		Layer:    s.currentLayer(),
+	}
+	s.ensureLayer(sprite.Layer)
+	if sprite.ID == "" {
+		sprite.ID = s.newSpriteID()
+	}
+
+	if i := s.spriteIndex(sprite.ID); i >= 0 {
+		s.Sprites[i] = sprite
+	} else {
+		s.Sprites = append(s.Sprites, sprite)
+	}
+	return false
+}
+
+type SpriteRemoveOp struct {
+	ID string
+}
+
+func (op SpriteRemoveOp) Do(t screen.Texture, s *State) bool {
+	if i := s.spriteIndex(op.ID); i >= 0 {
+		s.Sprites = append(s.Sprites[:i], s.Sprites[i+1:]...)
+	}
+	return false
+}
+
+// drawSprite composites the sprite over dst, skipping assets that are not
+// registered (yet).
+func drawSprite(dst draw.Image, sp Sprite, bounds image.Rectangle, assets *AssetStore) {
+	img, ok := assets.Lookup(sp.Asset)
+	if !ok {
+		return
+	}
+	origin := toPixels(sp.Position, bounds)
+	size := img.Bounds().Size()
+	if sp.Size.X > 0 && sp.Size.Y > 0 {
+		size = image.Pt(int(sp.Size.X*float64(bounds.Dx())), int(sp.Size.Y*float64(bounds.Dy())))
+	}
+	dr := image.Rectangle{Min: image.Pt(int(origin.X), int(origin.Y))}
+	dr.Max = dr.Min.Add(size)
+
+	if size == img.Bounds().Size() {
+		draw.Draw(dst, dr, img, img.Bounds().Min, draw.Over)
+		return
+	}
+	draw.BiLinear.Scale(dst, dr, img, img.Bounds(), draw.Over, nil)
+}
@@ -0,0 +1,68 @@
+package painter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"reflect"
+	"testing"
+)
+
+func TestAssetStore(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 3))
+	img.Set(1, 2, color.NRGBA{R: 0xff, A: 0xff})
+	var data bytes.Buffer
+	if err := png.Encode(&data, img); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	store := &AssetStore{Dir: dir}
+	if err := store.Write("store-test", data.Bytes()); err != nil {
+		t.Fatalf("Write failed: %s", err)
+	}
+	if err := store.Write("broken", []byte("not an image")); err == nil {
+		t.Error("Expected an error for data that is not an image")
+	}
+	if err := store.Write("../escape", data.Bytes()); err == nil {
+		t.Error("Expected an error for an asset name with a path")
+	}
+
+	names, err := store.List()
+	if err != nil || !reflect.DeepEqual(names, []string{"store-test"}) {
+		t.Errorf("Expected a single stored asset, got %v (%v)", names, err)
+	}
+
+	if _, ok := (&AssetStore{Dir: dir}).Lookup("store-test"); ok {
+		t.Error("Asset is registered before the store is loaded")
+	}
+	reloaded := &AssetStore{Dir: dir}
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load failed: %s", err)
+	}
+	loaded, ok := reloaded.Lookup("store-test")
+	if !ok || loaded == nil || loaded.Bounds().Size() != image.Pt(2, 3) {
+		t.Fatalf("Stored asset was not registered by Load: %v", loaded)
+	}
+	if _, _, _, a := loaded.At(1, 2).RGBA(); a != 0xffff {
+		t.Error("Stored asset lost its pixels")
+	}
+}
+
+func TestDecodeAsset_RejectsHugeImages(t *testing.T) {
+	var data bytes.Buffer
+	if err := gif.Encode(&data, image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.Black}), nil); err != nil {
+		t.Fatal(err)
+	}
+	// The logical screen size in the header is all DecodeConfig reads.
+	huge := data.Bytes()
+	binary.LittleEndian.PutUint16(huge[6:], 5000)
+	binary.LittleEndian.PutUint16(huge[8:], 5000)
+
+	if _, err := DecodeAsset(huge); err == nil {
+		t.Error("Expected an error for an image above the pixel limit")
+	}
+}
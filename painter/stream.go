@@ -0,0 +1,53 @@
+package painter
+
+import (
+	"image"
+	"sync"
+)
+
+// frameStream hands the frames drawn by a Loop to its subscribers. Every
+// subscriber only keeps the latest frame, so a slow one skips frames instead
+// of holding up the Loop.
+type frameStream struct {
+	mu          sync.Mutex
+	subscribers map[<-chan *image.RGBA]chan *image.RGBA
+}
+
+func (fs *frameStream) subscribe() <-chan *image.RGBA {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	ch := make(chan *image.RGBA, 1)
+	if fs.subscribers == nil {
+		fs.subscribers = map[<-chan *image.RGBA]chan *image.RGBA{}
+	}
+	fs.subscribers[ch] = ch
+	return ch
+}
+
+func (fs *frameStream) unsubscribe(ch <-chan *image.RGBA) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if sub, ok := fs.subscribers[ch]; ok {
+		delete(fs.subscribers, ch)
+		close(sub)
+	}
+}
+
+// publish copies the frame once for all the subscribers, if there are any.
+func (fs *frameStream) publish(img *image.RGBA) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if len(fs.subscribers) == 0 {
+		return
+	}
+	frame := image.NewRGBA(img.Bounds())
+	copy(frame.Pix, img.Pix)
+	for _, sub := range fs.subscribers {
+		// Replace a frame the subscriber has not taken yet.
+		select {
+		case <-sub:
+		default:
+		}
+		sub <- frame
+	}
+}
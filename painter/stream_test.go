@@ -0,0 +1,35 @@
+package painter
+
+import (
+	"image"
+	"testing"
+)
+
+func TestFrameStream_KeepsLatestFrame(t *testing.T) {
+	var fs frameStream
+	fs.publish(image.NewRGBA(image.Rect(0, 0, 1, 1)))
+
+	ch := fs.subscribe()
+	fs.publish(image.NewRGBA(image.Rect(0, 0, 1, 1)))
+	fs.publish(image.NewRGBA(image.Rect(0, 0, 2, 2)))
+
+	select {
+	case frame := <-ch:
+		if got := frame.Bounds().Size(); got != image.Pt(2, 2) {
+			t.Errorf("Expected the latest frame, got one of size %v", got)
+		}
+	default:
+		t.Fatal("No frame was published to the subscriber")
+	}
+	select {
+	case frame := <-ch:
+		t.Errorf("Expected a single pending frame, got another one: %v", frame.Bounds())
+	default:
+	}
+
+	fs.unsubscribe(ch)
+	if _, ok := <-ch; ok {
+		t.Error("Channel was not closed on unsubscribe")
+	}
+	fs.publish(image.NewRGBA(image.Rect(0, 0, 1, 1)))
+}
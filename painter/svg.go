@@ -0,0 +1,275 @@
+package painter
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// WriteSVG writes the state as an SVG document for a canvas of the given
+// size in pixels. Blend modes become CSS mix-blend-mode, which has no
+// equivalent of BlendXor, and sprites are embedded as PNG images of assets.
+func WriteSVG(w io.Writer, s *State, size image.Point, assets *AssetStore) error {
+	e := &svgEncoder{bounds: image.Rectangle{Max: size}, unit: float64(min(size.X, size.Y)), assets: assets}
+	e.printf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", size.X, size.Y, size.X, size.Y)
+
+	if s.BackgroundGradient != nil {
+		fill := e.gradient(s.BackgroundGradient, e.bounds)
+		e.printf(`<rect width="%d" height="%d" fill="%s"/>`+"\n", size.X, size.Y, fill)
+	} else {
+		e.printf(`<rect width="%d" height="%d"%s/>`+"\n", size.X, size.Y, paint("fill", "fill-opacity", s.BackgroundColor))
+	}
+
+	for _, layer := range s.drawingLayers() {
+		if layer.Hidden || layer.Opacity <= 0 {
+			continue
+		}
+		e.printf(`<g data-layer="%s"`, escapeXML(layer.Name))
+		if layer.Opacity < 1 {
+			e.printf(` opacity="%s"`, svgNumber(layer.Opacity))
+		}
+		e.printf(">\n")
+		if err := e.layer(s, layer); err != nil {
+			return err
+		}
+		e.printf("</g>\n")
+	}
+
+	e.printf("</svg>\n")
+	_, err := w.Write(e.buf.Bytes())
+	return err
+}
+
+type svgEncoder struct {
+	buf       bytes.Buffer
+	bounds    image.Rectangle
+	unit      float64
+	gradients int
+	assets    *AssetStore
+}
+
+func (e *svgEncoder) printf(format string, args ...any) {
+	fmt.Fprintf(&e.buf, format, args...)
+}
+
+// layer writes the elements of the layer in the order Render draws them.
+func (e *svgEncoder) layer(s *State, layer Layer) error {
+	for _, r := range s.BgRects {
+		if !onLayer(r.Layer, layer) {
+			continue
+		}
+		area := r.pixels(e.bounds.Size())
+		fill := paint("fill", "fill-opacity", r.Color)
+		if r.Gradient != nil {
+			fill = fmt.Sprintf(` fill="%s"`, e.gradient(r.Gradient, area))
+		}
+		e.printf(`<rect id="%s" x="%d" y="%d" width="%d" height="%d"%s%s/>`+"\n",
+			escapeXML(r.ID), area.Min.X, area.Min.Y, area.Dx(), area.Dy(), fill, blendStyle(r.Blend))
+	}
+
+	for _, sp := range s.Sprites {
+		if !onLayer(sp.Layer, layer) {
+			continue
+		}
+		if err := e.sprite(sp); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range s.Paths {
+		if onLayer(p.Layer, layer) {
+			e.path(p)
+		}
+	}
+
+	for _, f := range s.Figures {
+		if f.Hidden || !onLayer(f.Layer, layer) {
+			continue
+		}
+		shape, err := f.shape()
+		if err != nil {
+			continue
+		}
+		polygons := shapePolygons(Rotated(shape, f.Rotation), toPixels(f.Center, e.bounds), f.Size*e.unit)
+		e.printf(`<path id="%s" d="%s"%s%s/>`+"\n",
+			escapeXML(f.ID), polygonData(polygons), paint("fill", "fill-opacity", fadeColor(f.Color, f.Alpha)), blendStyle(f.Blend))
+	}
+
+	for _, text := range s.Texts {
+		at, ok := s.anchor(text)
+		if !ok || !onLayer(text.Layer, layer) {
+			continue
+		}
+		at = toPixels(at, e.bounds)
+		anchor := "start"
+		switch text.Align {
+		case AlignCenter:
+			anchor = "middle"
+		case AlignRight:
+			anchor = "end"
+		}
+		e.printf(`<text id="%s" x="%s" y="%s" font-family="Go, sans-serif" font-size="%s" text-anchor="%s" dominant-baseline="central"%s>%s</text>`+"\n",
+			escapeXML(text.ID), svgNumber(at.X), svgNumber(at.Y), svgNumber(text.Size*e.unit), anchor,
+			paint("fill", "fill-opacity", text.Color), escapeXML(text.Content))
+	}
+	return nil
+}
+
+// sprite embeds the asset of the sprite, skipping assets that are not
+// registered like drawSprite does.
+func (e *svgEncoder) sprite(sp Sprite) error {
+	img, ok := e.assets.Lookup(sp.Asset)
+	if !ok {
+		return nil
+	}
+	origin := toPixels(sp.Position, e.bounds)
+	size := img.Bounds().Size()
+	if sp.Size.X > 0 && sp.Size.Y > 0 {
+		size = image.Pt(int(sp.Size.X*float64(e.bounds.Dx())), int(sp.Size.Y*float64(e.bounds.Dy())))
+	}
+
+	var data bytes.Buffer
+	if err := png.Encode(&data, img); err != nil {
+		return fmt.Errorf("failed to encode sprite %s: %w", sp.ID, err)
+	}
+	e.printf(`<image id="%s" x="%d" y="%d" width="%d" height="%d" preserveAspectRatio="none" href="data:image/png;base64,%s"/>`+"\n",
+		escapeXML(sp.ID), int(origin.X), int(origin.Y), size.X, size.Y, base64.StdEncoding.EncodeToString(data.Bytes()))
+	return nil
+}
+
+func (e *svgEncoder) path(p Path) {
+	points := make([]Vec, len(p.Points))
+	for i, v := range p.Points {
+		points[i] = toPixels(v, e.bounds)
+	}
+	var d strings.Builder
+	for i, v := range points {
+		switch {
+		case i == 0:
+			d.WriteString("M")
+		case i == 1 && p.Kind == PathQuad:
+			d.WriteString(" Q")
+		case i == 1 && p.Kind == PathCubic:
+			d.WriteString(" C")
+		case p.Kind == PathQuad || p.Kind == PathCubic:
+			d.WriteString(",")
+		default:
+			d.WriteString(" L")
+		}
+		fmt.Fprintf(&d, "%s %s", svgNumber(v.X), svgNumber(v.Y))
+	}
+
+	e.printf(`<path id="%s" d="%s" fill="none"%s stroke-width="%s"`,
+		escapeXML(p.ID), d.String(), paint("stroke", "stroke-opacity", p.Color), svgNumber(p.Width*e.unit))
+	if p.Cap != "" {
+		e.printf(` stroke-linecap="%s"`, p.Cap)
+	}
+	if p.Join != "" {
+		e.printf(` stroke-linejoin="%s" stroke-miterlimit="%d"`, p.Join, miterLimit)
+	}
+	if pattern := dashPattern(p.Dash, e.unit); pattern != nil {
+		dashes := make([]string, len(pattern))
+		for i, d := range pattern {
+			dashes[i] = svgNumber(d)
+		}
+		e.printf(` stroke-dasharray="%s"`, strings.Join(dashes, " "))
+	}
+	e.printf("/>\n")
+}
+
+// gradient defines the gradient for an area of the canvas and returns a
+// reference to it.
+func (e *svgEncoder) gradient(g *Gradient, area image.Rectangle) string {
+	e.gradients++
+	id := fmt.Sprintf("gradient%d", e.gradients)
+	w, h := float64(area.Dx()), float64(area.Dy())
+	if g.Kind == GradientRadial {
+		e.printf(`<radialGradient id="%s" gradientUnits="userSpaceOnUse" cx="%s" cy="%s" r="%s">`+"\n", id,
+			svgNumber(float64(area.Min.X)+g.Center.X*w), svgNumber(float64(area.Min.Y)+g.Center.Y*h), svgNumber(g.Radius*math.Min(w, h)))
+	} else {
+		// The same gradient line as fillGradient: through the center of the
+		// area, long enough to reach its corners.
+		sin, cos := math.Sincos(g.Angle * math.Pi / 180)
+		length := math.Abs(w*cos) + math.Abs(h*sin)
+		cx, cy := float64(area.Min.X)+w/2, float64(area.Min.Y)+h/2
+		e.printf(`<linearGradient id="%s" gradientUnits="userSpaceOnUse" x1="%s" y1="%s" x2="%s" y2="%s">`+"\n", id,
+			svgNumber(cx-cos*length/2), svgNumber(cy-sin*length/2), svgNumber(cx+cos*length/2), svgNumber(cy+sin*length/2))
+	}
+	for _, stop := range g.Stops {
+		e.printf(`<stop offset="%s"%s/>`+"\n", svgNumber(stop.Offset), paint("stop-color", "stop-opacity", stop.Color))
+	}
+	if g.Kind == GradientRadial {
+		e.printf("</radialGradient>\n")
+	} else {
+		e.printf("</linearGradient>\n")
+	}
+	return fmt.Sprintf("url(#%s)", id)
+}
+
+// paint returns the attributes for a color, leaving out the opacity of
+// opaque ones.
+func paint(colorAttr, opacityAttr string, c color.Color) string {
+	if c == nil {
+		return fmt.Sprintf(` %s="none"`, colorAttr)
+	}
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	res := fmt.Sprintf(` %s="#%02x%02x%02x"`, colorAttr, n.R, n.G, n.B)
+	if n.A < 0xff {
+		res += fmt.Sprintf(` %s="%s"`, opacityAttr, svgNumber(float64(n.A)/0xff))
+	}
+	return res
+}
+
+func blendStyle(mode BlendMode) string {
+	switch mode {
+	case BlendMultiply, BlendScreen:
+		return fmt.Sprintf(` style="mix-blend-mode:%s"`, mode)
+	case BlendAdd:
+		return ` style="mix-blend-mode:plus-lighter"`
+	}
+	return ""
+}
+
+// polygonData traces all polygons in the same direction, so the nonzero
+// fill rule fills their union like fillPolygons does.
+func polygonData(polygons [][]Vec) string {
+	var d strings.Builder
+	for _, points := range polygons {
+		if len(points) < 3 {
+			continue
+		}
+		reversed := signedArea(points) < 0
+		for i := range points {
+			p := points[i]
+			if reversed {
+				p = points[len(points)-1-i]
+			}
+			if i == 0 {
+				fmt.Fprintf(&d, "M%s %s", svgNumber(p.X), svgNumber(p.Y))
+			} else {
+				fmt.Fprintf(&d, " L%s %s", svgNumber(p.X), svgNumber(p.Y))
+			}
+		}
+		d.WriteString(" Z ")
+	}
+	return strings.TrimSpace(d.String())
+}
+
+func svgNumber(v float64) string {
+	// Adding zero turns negative zero into zero.
+	return strconv.FormatFloat(math.Round(v*1000)/1000+0, 'f', -1, 64)
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
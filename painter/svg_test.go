@@ -0,0 +1,66 @@
+package painter
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image"
+	"image/color"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWriteSVG(t *testing.T) {
+	s := DefaultState()
+	s.BackgroundColor = color.White
+	LayerOp{Name: "hud"}.Do(nil, s)
+	LayerOpacityOp{Name: "hud", Opacity: 0.5}.Do(nil, s)
+	s.BgRects = []BgRect{
+		{ID: "r1", Rect: Rect{Min: Vec{X: 0, Y: 0}, Max: Vec{X: 0.5, Y: 0.25}}, Color: color.NRGBA{R: 0xff, A: 0x80}, Blend: BlendMultiply, Layer: DefaultLayer},
+		{ID: "r2", Rect: Rect{Min: Vec{X: 0.5, Y: 0.5}, Max: Vec{X: 1, Y: 1}}, Gradient: NewLinearGradient(0, []GradientStop{{0, color.Black}, {1, color.White}}), Layer: "hud"},
+	}
+	f := NewFigure("f1", Vec{X: 0.5, Y: 0.5})
+	f.Shape, f.Size, f.Alpha = "rectangle", 0.1, 0.5
+	f.Color = color.NRGBA{B: 0xff, A: 0xff}
+	s.Figures = []Figure{f, {ID: "hidden", Hidden: true, Layer: DefaultLayer}}
+	s.Paths = []Path{{ID: "p1", Kind: PathQuad, Points: []Vec{{0, 0}, {0.5, 1}, {1, 0}}, Stroke: Stroke{Width: 0.01, Color: color.White, Dash: []float64{0.02}}, Layer: DefaultLayer}}
+	s.Texts = []Text{{ID: "t1", Content: `<a & "b">`, Position: Vec{X: 0.5, Y: 0.1}, Size: 0.05, Color: color.Black, Align: AlignCenter, Layer: DefaultLayer}}
+
+	var buf bytes.Buffer
+	if err := WriteSVG(&buf, s, image.Pt(200, 100), nil); err != nil {
+		t.Fatalf("WriteSVG failed: %s", err)
+	}
+	svg := buf.String()
+
+	d := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		if _, err := d.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Output is not well-formed XML: %s\n%s", err, svg)
+		}
+	}
+
+	for _, want := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg" width="200" height="100" viewBox="0 0 200 100">`,
+		`<rect width="200" height="100" fill="#ffffff"/>`,
+		`<rect id="r1" x="0" y="0" width="100" height="25" fill="#ff0000" fill-opacity="0.502" style="mix-blend-mode:multiply"/>`,
+		`<g data-layer="hud" opacity="0.5">`,
+		`<linearGradient id="gradient1" gradientUnits="userSpaceOnUse" x1="100" y1="75" x2="200" y2="75">`,
+		`<rect id="r2" x="100" y="50" width="100" height="50" fill="url(#gradient1)"/>`,
+		`<path id="f1" d="M95 45 L105 45 L105 55 L95 55 Z" fill="#0000ff" fill-opacity="0.502"/>`,
+		`<path id="p1" d="M0 0 Q100 100,200 0" fill="none" stroke="#ffffff" stroke-width="1" stroke-dasharray="2 2"/>`,
+		`text-anchor="middle"`,
+		`>&lt;a &amp; &#34;b&#34;&gt;</text>`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("Expected %s in\n%s", want, svg)
+		}
+	}
+	if strings.Contains(svg, `id="hidden"`) {
+		t.Errorf("Hidden figure was exported:\n%s", svg)
+	}
+	if strings.Index(svg, `id="r1"`) > strings.Index(svg, `data-layer="hud"`) {
+		t.Errorf("Layers are out of order:\n%s", svg)
+	}
+}
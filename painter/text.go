@@ -0,0 +1,190 @@
+package painter
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"sync"
+
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+type TextAlign string
+
+const (
+	AlignLeft   TextAlign = "left"
+	AlignCenter TextAlign = "center"
+	AlignRight  TextAlign = "right"
+)
+
+// DefaultTextSize is the font size relative to the smaller texture side.
+const DefaultTextSize = 0.05
+
+// MaxTextSize bounds the font size, as drawing glyphs gets slow with their
+// area and texts are drawn on every frame.
+const MaxTextSize = 0.25
+
+var DefaultTextColor color.Color = color.White
+
+// Text is a single line of text whose vertical middle is placed at Position.
+// Texts attached to a figure follow it: their Position is an offset from the
+// figure center and they are hidden along with the figure.
+type Text struct {
+	ID       string
+	Content  string
+	Position Vec
+	Size     float64
+	Color    color.Color
+	Align    TextAlign
+	Figure   string
+	Layer    string
+}
+
+func ValidTextAlign(a TextAlign) bool {
+	switch a {
+	case "", AlignLeft, AlignCenter, AlignRight:
+		return true
+	}
+	return false
+}
+
+func ValidTextSize(size float64) bool {
+	return size > 0 && size <= MaxTextSize
+}
+
+func (s *State) textIndex(id string) int {
+	for i := range s.Texts {
+		if s.Texts[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *State) newTextID() string {
+	for n := len(s.Texts) + 1; ; n++ {
+		id := fmt.Sprintf("t%d", n)
+		if s.textIndex(id) < 0 {
+			return id
+		}
+	}
+}
+
+// TextOp adds a text to the current layer or replaces the one with the same
+// ID. Zero Size, Color and Align mean defaults.
+type TextOp struct {
+	ID      string
+	Content string
+	X, Y    float64
+	Size    float64
+	Color   color.Color
+	Align   TextAlign
+	Figure  string
+}
+
+func (op TextOp) Do(t screen.Texture, s *State) bool {
+	text := Text{
+		ID:       op.ID,
+		Content:  op.Content,
+		Position: Vec{X: op.X, Y: op.Y},
+		Size:     op.Size,
+		Color:    op.Color,
+		Align:    op.Align,
+		Figure:   op.Figure,
+		Layer:    s.currentLayer(),
+	}
+	s.ensureLayer(text.Layer)
+	if text.Size == 0 {
+		text.Size = DefaultTextSize
+	}
+	if text.Color == nil {
+		text.Color = DefaultTextColor
+	}
+	if text.Align == "" {
+		text.Align = AlignLeft
+	}
+	if text.ID == "" {
+		text.ID = s.newTextID()
+	}
+
+	if i := s.textIndex(text.ID); i >= 0 {
+		s.Texts[i] = text
+	} else {
+		s.Texts = append(s.Texts, text)
+	}
+	return false
+}
+
+type TextRemoveOp struct {
+	ID string
+}
+
+func (op TextRemoveOp) Do(t screen.Texture, s *State) bool {
+	if i := s.textIndex(op.ID); i >= 0 {
+		s.Texts = append(s.Texts[:i], s.Texts[i+1:]...)
+	}
+	return false
+}
+
+var (
+	fontOnce    sync.Once
+	defaultFont *opentype.Font
+	fontErr     error
+)
+
+func loadFont() (*opentype.Font, error) {
+	fontOnce.Do(func() {
+		defaultFont, fontErr = opentype.Parse(goregular.TTF)
+	})
+	return defaultFont, fontErr
+}
+
+// anchor returns the text position in relative units, or false when the
+// text should not be drawn because its figure is missing or hidden.
+func (s *State) anchor(t Text) (Vec, bool) {
+	if t.Figure == "" {
+		return t.Position, true
+	}
+	f := s.Figure(t.Figure)
+	if f == nil || f.Hidden {
+		return Vec{}, false
+	}
+	return Vec{X: f.Center.X + t.Position.X, Y: f.Center.Y + t.Position.Y}, true
+}
+
+// DrawText composites a line of text over dst using the bundled Go font.
+// size is the font size in pixels.
+func DrawText(dst draw.Image, content string, at Vec, size float64, align TextAlign, c color.Color) error {
+	f, err := loadFont()
+	if err != nil {
+		return err
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+	if err != nil {
+		return err
+	}
+	defer face.Close()
+
+	d := font.Drawer{Dst: dst, Src: image.NewUniform(c), Face: face}
+	width := d.MeasureString(content)
+	metrics := face.Metrics()
+
+	dot := fixed.Point26_6{
+		X: fixed.Int26_6(at.X * 64),
+		Y: fixed.Int26_6(at.Y*64) + (metrics.Ascent-metrics.Descent)/2,
+	}
+	switch align {
+	case AlignCenter:
+		dot.X -= width / 2
+	case AlignRight:
+		dot.X -= width
+	}
+	d.Dot = dot
+	d.DrawString(content)
+	return nil
+}
@@ -0,0 +1,257 @@
+package painter
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+	"time"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+type TimelineMode string
+
+const (
+	TimelineOnce     TimelineMode = "once"
+	TimelineLoop     TimelineMode = "loop"
+	TimelinePingPong TimelineMode = "pingpong"
+)
+
+// Keyframe sets properties of a figure at a point of a timeline. Properties
+// left nil are interpolated between the keyframes that do set them. Easing
+// shapes the way from this keyframe to the next one.
+type Keyframe struct {
+	At     time.Duration
+	Figure string
+	Center *Vec
+	Color  color.Color
+	Size   *float64
+	Alpha  *float64
+	Easing Easing
+}
+
+// Timeline animates figures through keyframes. It is played by the Loop.
+type Timeline struct {
+	Name      string
+	Mode      TimelineMode
+	Keyframes []Keyframe
+}
+
+func ValidateTimeline(tl *Timeline) error {
+	if tl.Name == "" {
+		return fmt.Errorf("timeline requires a name")
+	}
+	switch tl.Mode {
+	case "", TimelineOnce, TimelineLoop, TimelinePingPong:
+	default:
+		return fmt.Errorf("unknown timeline mode: %s", tl.Mode)
+	}
+	if len(tl.Keyframes) == 0 {
+		return fmt.Errorf("timeline %s has no keyframes", tl.Name)
+	}
+	for _, k := range tl.Keyframes {
+		if k.At < 0 {
+			return fmt.Errorf("keyframe time must not be negative")
+		}
+		if k.Figure == "" {
+			return fmt.Errorf("keyframe requires a figure")
+		}
+		if k.Center == nil && k.Color == nil && k.Size == nil && k.Alpha == nil {
+			return fmt.Errorf("keyframe at %s sets no properties of %s", k.At, k.Figure)
+		}
+		if !ValidEasing(k.Easing) {
+			return fmt.Errorf("unknown easing: %s", k.Easing)
+		}
+	}
+	return nil
+}
+
+// Duration is the time of the last keyframe.
+func (tl *Timeline) Duration() time.Duration {
+	var d time.Duration
+	for _, k := range tl.Keyframes {
+		d = max(d, k.At)
+	}
+	return d
+}
+
+// position maps the time a timeline has been playing to a point of it and
+// reports whether it has come to an end.
+func (tl *Timeline) position(played time.Duration) (time.Duration, bool) {
+	d := tl.Duration()
+	switch {
+	case d == 0:
+		return 0, tl.Mode == "" || tl.Mode == TimelineOnce
+	case tl.Mode == TimelineLoop:
+		return played % d, false
+	case tl.Mode == TimelinePingPong:
+		p := played % (2 * d)
+		if p > d {
+			p = 2*d - p
+		}
+		return p, false
+	default:
+		return min(played, d), played >= d
+	}
+}
+
+// apply sets the properties the figures have at the point at of the
+// timeline.
+func (tl *Timeline) apply(s *State, at time.Duration) {
+	for i := range s.Figures {
+		f := &s.Figures[i]
+		if a, b, k, ok := tl.segment(f.ID, at, func(kf Keyframe) bool { return kf.Center != nil }); ok {
+			f.Center = lerpVec(*a.Center, *b.Center, k)
+		}
+		if a, b, k, ok := tl.segment(f.ID, at, func(kf Keyframe) bool { return kf.Color != nil }); ok {
+			f.Color = approachColor(a.Color, b.Color, 0, k)
+		}
+		if a, b, k, ok := tl.segment(f.ID, at, func(kf Keyframe) bool { return kf.Size != nil }); ok {
+			f.Size = approach(*a.Size, *b.Size, 0, k)
+		}
+		if a, b, k, ok := tl.segment(f.ID, at, func(kf Keyframe) bool { return kf.Alpha != nil }); ok {
+			f.Alpha = approach(*a.Alpha, *b.Alpha, 0, k)
+		}
+	}
+}
+
+// segment finds the keyframes of the figure around at among those that set a
+// property, and the eased progress from the first to the second.
+func (tl *Timeline) segment(figure string, at time.Duration, sets func(Keyframe) bool) (a, b Keyframe, k float64, ok bool) {
+	var frames []Keyframe
+	for _, kf := range tl.Keyframes {
+		if kf.Figure == figure && sets(kf) {
+			frames = append(frames, kf)
+		}
+	}
+	if len(frames) == 0 {
+		return Keyframe{}, Keyframe{}, 0, false
+	}
+	sort.SliceStable(frames, func(i, j int) bool { return frames[i].At < frames[j].At })
+
+	i := sort.Search(len(frames), func(i int) bool { return frames[i].At > at })
+	switch {
+	case i == 0:
+		return frames[0], frames[0], 0, true
+	case i == len(frames):
+		return frames[i-1], frames[i-1], 0, true
+	}
+	a, b = frames[i-1], frames[i]
+	t := float64(at-a.At) / float64(b.At-a.At)
+	return a, b, a.Easing.apply(t), true
+}
+
+// TimelineOp defines a timeline, replacing the one with the same name. It
+// does not start playing until a PlayOp.
+type TimelineOp struct {
+	Timeline *Timeline
+}
+
+func (op TimelineOp) Do(t screen.Texture, s *State) bool {
+	return false
+}
+
+// PlayOp starts or resumes playing a timeline. A timeline that has come to
+// an end is played again from the start.
+type PlayOp struct {
+	Name string
+}
+
+func (op PlayOp) Do(t screen.Texture, s *State) bool {
+	return false
+}
+
+type PauseOp struct {
+	Name string
+}
+
+func (op PauseOp) Do(t screen.Texture, s *State) bool {
+	return false
+}
+
+// SeekOp moves a timeline to the given time and applies it, whether it is
+// playing or not.
+type SeekOp struct {
+	Name string
+	At   time.Duration
+}
+
+func (op SeekOp) Do(t screen.Texture, s *State) bool {
+	return false
+}
+
+type playback struct {
+	timeline *Timeline
+	played   time.Duration
+	playing  bool
+	ended    bool
+	last     time.Time
+}
+
+// timelines holds the timelines defined in a Loop, in the order they are
+// applied, and their playback.
+type timelines []*playback
+
+func (ts timelines) find(name string) *playback {
+	for _, p := range ts {
+		if p.timeline.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+func (ts *timelines) define(tl *Timeline) {
+	if p := ts.find(tl.Name); p != nil {
+		*p = playback{timeline: tl}
+		return
+	}
+	*ts = append(*ts, &playback{timeline: tl})
+}
+
+func (ts timelines) play(name string, now time.Time) {
+	if p := ts.find(name); p != nil && !p.playing {
+		if p.ended {
+			p.played, p.ended = 0, false
+		}
+		p.playing, p.last = true, now
+	}
+}
+
+func (ts timelines) pause(name string, now time.Time) {
+	if p := ts.find(name); p != nil && p.playing {
+		p.played += now.Sub(p.last)
+		p.playing = false
+	}
+}
+
+func (ts timelines) seek(s *State, name string, at time.Duration, now time.Time) bool {
+	p := ts.find(name)
+	if p == nil {
+		return false
+	}
+	p.played, p.last, p.ended = max(0, at), now, false
+	position, _ := p.timeline.position(p.played)
+	p.timeline.apply(s, position)
+	return true
+}
+
+// advance applies the playing timelines at now and reports whether there
+// was any.
+func (ts timelines) advance(s *State, now time.Time) bool {
+	advanced := false
+	for _, p := range ts {
+		if !p.playing {
+			continue
+		}
+		p.played += now.Sub(p.last)
+		p.last = now
+		position, ended := p.timeline.position(p.played)
+		p.timeline.apply(s, position)
+		if ended {
+			p.playing, p.ended = false, true
+		}
+		advanced = true
+	}
+	return advanced
+}
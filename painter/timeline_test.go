@@ -0,0 +1,98 @@
+package painter
+
+import (
+	"image/color"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTimeline_Position(t *testing.T) {
+	tl := &Timeline{Keyframes: []Keyframe{{At: 0}, {At: 2 * time.Second}}}
+	tests := []struct {
+		mode   TimelineMode
+		played time.Duration
+		at     time.Duration
+		ended  bool
+	}{
+		{TimelineOnce, time.Second, time.Second, false},
+		{TimelineOnce, 3 * time.Second, 2 * time.Second, true},
+		{TimelineLoop, 3 * time.Second, time.Second, false},
+		{TimelinePingPong, 3 * time.Second, time.Second, false},
+		{TimelinePingPong, 5 * time.Second, time.Second, false},
+	}
+	for _, tt := range tests {
+		tl.Mode = tt.mode
+		if at, ended := tl.position(tt.played); at != tt.at || ended != tt.ended {
+			t.Errorf("%s after %s: expected %s (ended %v), got %s (ended %v)", tt.mode, tt.played, tt.at, tt.ended, at, ended)
+		}
+	}
+}
+
+func TestTimeline_Apply(t *testing.T) {
+	size := 0.5
+	tl := &Timeline{Name: "t", Keyframes: []Keyframe{
+		{At: 0, Figure: "a", Center: &Vec{X: 0, Y: 0}, Color: color.Black},
+		{At: time.Second, Figure: "a", Size: &size},
+		{At: 2 * time.Second, Figure: "a", Center: &Vec{X: 1, Y: 0.5}, Color: color.White},
+	}}
+	s := DefaultState()
+	s.Figures = []Figure{NewFigure("a", Vec{}), NewFigure("b", Vec{X: 0.5, Y: 0.5})}
+
+	tl.apply(s, 500*time.Millisecond)
+	a := s.Figure("a")
+	if math.Abs(a.Center.X-0.25) > 1e-9 || math.Abs(a.Center.Y-0.125) > 1e-9 {
+		t.Errorf("Center is not interpolated between keyframes: %v", a.Center)
+	}
+	if a.Size != size {
+		t.Errorf("Expected the only size keyframe to hold over the whole timeline, got %v", a.Size)
+	}
+	if c := color.NRGBAModel.Convert(a.Color).(color.NRGBA); c.R != 64 || c.A != 255 {
+		t.Errorf("Color is not interpolated between keyframes: %v", c)
+	}
+	if s.Figure("b").Center != (Vec{X: 0.5, Y: 0.5}) {
+		t.Errorf("Figure without keyframes was changed")
+	}
+
+	tl.apply(s, 3*time.Second)
+	if a.Center != (Vec{X: 1, Y: 0.5}) {
+		t.Errorf("Expected the last keyframe after the end, got %v", a.Center)
+	}
+}
+
+func TestTimelines_Playback(t *testing.T) {
+	s := DefaultState()
+	s.Figures = []Figure{NewFigure("a", Vec{})}
+	var ts timelines
+	ts.define(&Timeline{Name: "slide", Keyframes: []Keyframe{
+		{At: 0, Figure: "a", Center: &Vec{X: 0, Y: 0}},
+		{At: time.Second, Figure: "a", Center: &Vec{X: 1, Y: 0}},
+	}})
+	start := time.Now()
+
+	if ts.advance(s, start) {
+		t.Error("Timeline advanced before it was played")
+	}
+	ts.play("slide", start)
+	ts.advance(s, start.Add(250*time.Millisecond))
+	ts.pause("slide", start.Add(500*time.Millisecond))
+	if ts.advance(s, start.Add(time.Second)) {
+		t.Error("Paused timeline advanced")
+	}
+	ts.play("slide", start.Add(2*time.Second))
+	ts.advance(s, start.Add(2250*time.Millisecond))
+	if x := s.Figure("a").Center.X; math.Abs(x-0.75) > 1e-9 {
+		t.Errorf("Expected playback to resume where it was paused, got x = %v", x)
+	}
+
+	ts.advance(s, start.Add(5*time.Second))
+	if ts.advance(s, start.Add(6*time.Second)) {
+		t.Error("Timeline played past its end")
+	}
+	if !ts.seek(s, "slide", 100*time.Millisecond, start) || math.Abs(s.Figure("a").Center.X-0.1) > 1e-9 {
+		t.Errorf("Seek did not apply the timeline, got %v", s.Figure("a").Center)
+	}
+	if ts.seek(s, "missing", 0, start) {
+		t.Error("Seek on an unknown timeline reported a change")
+	}
+}
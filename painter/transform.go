@@ -0,0 +1,80 @@
+package painter
+
+import (
+	"math"
+	"slices"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// Rotated returns the shape turned clockwise on screen by the given angle in
+// degrees. Vertices of the result may leave the unit square.
+func Rotated(shape Shape, degrees float64) Shape {
+	if math.Mod(degrees, 360) == 0 {
+		return shape
+	}
+	sin, cos := math.Sincos(degrees * math.Pi / 180)
+	var rotated Polygons
+	for _, polygon := range shape.Polygons() {
+		points := make([]Vec, len(polygon))
+		for i, v := range polygon {
+			points[i] = Vec{X: v.X*cos - v.Y*sin, Y: v.X*sin + v.Y*cos}
+		}
+		rotated = append(rotated, points)
+	}
+	return rotated
+}
+
+func selected(ids []string, f *Figure) bool {
+	return len(ids) == 0 || slices.Contains(ids, f.ID)
+}
+
+// RotateOp turns the selected figures (all of them when IDs is empty) by
+// Degrees. With a Pivot the figures also orbit around it; otherwise each one
+// turns in place.
+type RotateOp struct {
+	IDs     []string
+	Degrees float64
+	Pivot   *Vec
+}
+
+func (op RotateOp) Do(t screen.Texture, s *State) bool {
+	sin, cos := math.Sincos(op.Degrees * math.Pi / 180)
+	for i := range s.Figures {
+		f := &s.Figures[i]
+		if !selected(op.IDs, f) {
+			continue
+		}
+		f.Rotation = math.Mod(f.Rotation+op.Degrees, 360)
+		if op.Pivot != nil {
+			dx, dy := f.Center.X-op.Pivot.X, f.Center.Y-op.Pivot.Y
+			f.Center = Vec{X: op.Pivot.X + dx*cos - dy*sin, Y: op.Pivot.Y + dx*sin + dy*cos}
+		}
+	}
+	return false
+}
+
+// ScaleOp multiplies the size of the selected figures by Factor. With a Pivot
+// the distance of every figure from it is scaled as well.
+type ScaleOp struct {
+	IDs    []string
+	Factor float64
+	Pivot  *Vec
+}
+
+func (op ScaleOp) Do(t screen.Texture, s *State) bool {
+	for i := range s.Figures {
+		f := &s.Figures[i]
+		if !selected(op.IDs, f) {
+			continue
+		}
+		f.Size *= op.Factor
+		if op.Pivot != nil {
+			f.Center = Vec{
+				X: op.Pivot.X + (f.Center.X-op.Pivot.X)*op.Factor,
+				Y: op.Pivot.Y + (f.Center.Y-op.Pivot.Y)*op.Factor,
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,14 @@
+#!/bin/bash
+
+# Reset the drawing state and draw a figure on a white background
+curl -X POST -d "reset
+white
+figure id=hero 0.2 0.5
+update" http://localhost:17000/
+
+echo "Animating the figure on the server..."
+
+# The painter interpolates every animation over frames and redraws by itself
+curl -X POST -d "animate move hero 0.6 0 duration=2s easing=ease-in-out
+animate rotate id=hero 360 duration=2s
+animate style hero color=red size=0.1 duration=2s" http://localhost:17000/
@@ -0,0 +1,22 @@
+#!/bin/bash
+
+# Reset the drawing state and draw two figures moving towards each other
+curl -X POST -d "reset
+white
+bgrect id=wall 0.45 0 0.55 0.2
+figure id=left 0.2 0.5
+figure id=right 0.8 0.5
+velocity left 0.2 0
+velocity right -0.2 0
+boundary bounce" http://localhost:17000/
+
+# Turn both figures around and recolor them when they meet
+curl -X POST -d "on collide left right {
+  velocity left -0.2 -0.1
+  velocity right 0.2 0.1
+  style left color=red
+  style right color=blue
+}" http://localhost:17000/
+
+echo "Watching collisions (Ctrl+C to stop)..."
+curl -N http://localhost:17000/events
@@ -0,0 +1,17 @@
+#!/bin/bash
+
+# Reset the drawing state and draw a figure on a white background
+curl -X POST -d "reset
+white
+figure id=hero 0.2 0.5
+update" http://localhost:17000/
+
+echo "Playing the timeline on the server..."
+
+# The figure goes back and forth forever, changing color and size on the way
+curl -X POST -d "timeline bounce mode=pingpong {
+  0s hero center=0.2,0.5 color=yellow size=0.25 alpha=1 easing=ease-in-out
+  1s hero center=0.5,0.3 color=orange size=0.15 easing=ease-in-out
+  2s hero center=0.8,0.5 color=red size=0.25 alpha=0.5
+}
+play bounce" http://localhost:17000/
@@ -0,0 +1,17 @@
+#!/bin/bash
+
+# Reset the drawing state and start recording with a figure on a white background
+curl -X POST -d "reset
+white
+figure id=hero 0.2 0.5
+update
+record start" http://localhost:17000/
+
+echo "Recording the animation for 2 seconds..."
+
+curl -X POST -d "animate move hero 0.6 0 duration=2s easing=ease-in-out" http://localhost:17000/
+sleep 2
+
+# Stop recording and download the animated GIF once it has been encoded
+curl -X POST -d "record stop" http://localhost:17000/
+curl -o recording.gif http://localhost:17000/recording.gif
@@ -0,0 +1,94 @@
+// Package headless implements shiny screens that keep everything in memory,
+// so the painter can run without a display.
+package headless
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"sync"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// Screen creates buffers and textures backed by image.RGBA. It cannot open
+// windows.
+type Screen struct{}
+
+func (Screen) NewBuffer(size image.Point) (screen.Buffer, error) {
+	return &Buffer{rgba: image.NewRGBA(image.Rectangle{Max: size})}, nil
+}
+
+func (Screen) NewTexture(size image.Point) (screen.Texture, error) {
+	return &Texture{rgba: image.NewRGBA(image.Rectangle{Max: size})}, nil
+}
+
+func (Screen) NewWindow(opts *screen.NewWindowOptions) (screen.Window, error) {
+	return nil, errors.New("headless screen cannot open windows")
+}
+
+type Buffer struct {
+	rgba *image.RGBA
+}
+
+func (b *Buffer) Release() {}
+
+func (b *Buffer) Size() image.Point { return b.rgba.Bounds().Size() }
+
+func (b *Buffer) Bounds() image.Rectangle { return b.rgba.Bounds() }
+
+func (b *Buffer) RGBA() *image.RGBA { return b.rgba }
+
+type Texture struct {
+	rgba *image.RGBA
+}
+
+func (t *Texture) Release() {}
+
+func (t *Texture) Size() image.Point { return t.rgba.Bounds().Size() }
+
+func (t *Texture) Bounds() image.Rectangle { return t.rgba.Bounds() }
+
+func (t *Texture) Upload(dp image.Point, src screen.Buffer, sr image.Rectangle) {
+	draw.Draw(t.rgba, sr.Sub(sr.Min).Add(dp), src.RGBA(), sr.Min, draw.Src)
+}
+
+func (t *Texture) Fill(dr image.Rectangle, src color.Color, op draw.Op) {
+	draw.Draw(t.rgba, dr, image.NewUniform(src), image.Point{}, op)
+}
+
+// RGBA returns the pixels of the texture. They change with every upload.
+func (t *Texture) RGBA() *image.RGBA { return t.rgba }
+
+// Receiver keeps a copy of the last texture it was updated with, in place of
+// a window.
+type Receiver struct {
+	mu    sync.Mutex
+	frame *image.RGBA
+}
+
+func (r *Receiver) Update(t screen.Texture) {
+	tex, ok := t.(*Texture)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.frame == nil || r.frame.Bounds() != tex.rgba.Bounds() {
+		r.frame = image.NewRGBA(tex.rgba.Bounds())
+	}
+	copy(r.frame.Pix, tex.rgba.Pix)
+}
+
+// Frame returns a copy of the last frame, or nil before the first update.
+func (r *Receiver) Frame() *image.RGBA {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.frame == nil {
+		return nil
+	}
+	frame := image.NewRGBA(r.frame.Bounds())
+	copy(frame.Pix, r.frame.Pix)
+	return frame
+}
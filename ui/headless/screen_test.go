@@ -0,0 +1,59 @@
+package headless
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestTexture_UploadAndFill(t *testing.T) {
+	s := Screen{}
+	b, _ := s.NewBuffer(image.Pt(4, 4))
+	draw.Draw(b.RGBA(), b.Bounds(), image.NewUniform(color.RGBA{R: 0xff, A: 0xff}), image.Point{}, draw.Src)
+
+	tex, _ := s.NewTexture(image.Pt(8, 8))
+	tex.Upload(image.Pt(2, 2), b, image.Rect(1, 1, 3, 3))
+	tex.Fill(image.Rect(0, 0, 1, 1), color.RGBA{B: 0xff, A: 0xff}, draw.Src)
+
+	pixels := tex.(*Texture).RGBA()
+	tests := []struct {
+		x, y int
+		want color.RGBA
+	}{
+		{0, 0, color.RGBA{B: 0xff, A: 0xff}},
+		{2, 2, color.RGBA{R: 0xff, A: 0xff}},
+		{3, 3, color.RGBA{R: 0xff, A: 0xff}},
+		{4, 4, color.RGBA{}},
+	}
+	for _, tt := range tests {
+		if got := pixels.RGBAAt(tt.x, tt.y); got != tt.want {
+			t.Errorf("pixel (%d, %d) = %v, want %v", tt.x, tt.y, got, tt.want)
+		}
+	}
+
+	if _, err := s.NewWindow(nil); err == nil {
+		t.Error("expected an error when opening a window")
+	}
+}
+
+func TestReceiver_Frame(t *testing.T) {
+	var r Receiver
+	if r.Frame() != nil {
+		t.Fatal("expected no frame before the first update")
+	}
+
+	tex, _ := Screen{}.NewTexture(image.Pt(2, 2))
+	tex.Fill(tex.Bounds(), color.White, draw.Src)
+	r.Update(tex)
+	tex.Fill(tex.Bounds(), color.Black, draw.Src)
+
+	frame := r.Frame()
+	if got := frame.RGBAAt(1, 1); got != (color.RGBA{0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("frame changed with the texture: %v", got)
+	}
+	frame.SetRGBA(0, 0, color.RGBA{})
+	if got := r.Frame().RGBAAt(0, 0); got.A != 0xff {
+		t.Errorf("frame is shared with the caller: %v", got)
+	}
+}
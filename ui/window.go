@@ -2,7 +2,6 @@ package ui
 
 import (
 	"image"
-	"image/color"
 	"log"
 
 	"golang.org/x/exp/shiny/driver"
@@ -14,13 +13,17 @@ import (
 	"golang.org/x/mobile/event/mouse"
 	"golang.org/x/mobile/event/paint"
 	"golang.org/x/mobile/event/size"
+
+	"github.com/maxnetyaga/software-architecture-lab3/painter"
 )
 
 type Visualizer struct {
 	Title         string
 	Debug         bool
 	OnScreenReady func(s screen.Screen)
+	OnResize      func(size image.Point)
 
+	s    screen.Screen
 	w    screen.Window
 	tx   chan screen.Texture
 	done chan struct{}
@@ -58,6 +61,7 @@ func (pw *Visualizer) run(s screen.Screen) {
 		pw.OnScreenReady(s)
 	}
 
+	pw.s = s
 	pw.w = w
 
 	events := make(chan any)
@@ -110,6 +114,9 @@ func (pw *Visualizer) handleEvent(e any, t screen.Texture) {
 
 	case size.Event:
 		pw.sz = e
+		if pw.OnResize != nil {
+			pw.OnResize(e.Size())
+		}
 
 	case error:
 		log.Printf("ERROR: %s", e)
@@ -131,25 +138,27 @@ func (pw *Visualizer) handleEvent(e any, t screen.Texture) {
 }
 
 func (pw *Visualizer) drawDefaultUI() {
-	pw.w.Fill(pw.sz.Bounds(), color.White, draw.Src)
-
-	for _, br := range imageutil.Border(pw.sz.Bounds(), 10) {
-		pw.w.Fill(br, color.White, draw.Src)
+	b, err := pw.s.NewBuffer(pw.sz.Size())
+	if err != nil {
+		log.Printf("ERROR: %s", err)
+		return
 	}
+	defer b.Release()
 
-	figureColor := color.RGBA{R: 255, G: 255, B: 0, A: 255}
-	figureSize := 200
+	draw.Draw(b.RGBA(), b.Bounds(), image.White, image.Point{}, draw.Src)
 
-	halfFigureSize := figureSize / 2
-	horizontalRect := image.Rectangle{
-		Min: image.Point{X: pw.figureCenter.X - halfFigureSize, Y: pw.figureCenter.Y - halfFigureSize/3},
-		Max: image.Point{X: pw.figureCenter.X + halfFigureSize, Y: pw.figureCenter.Y + halfFigureSize/3},
-	}
-	verticalRect := image.Rectangle{
-		Min: image.Point{X: pw.figureCenter.X - halfFigureSize/3, Y: pw.figureCenter.Y - halfFigureSize},
-		Max: image.Point{X: pw.figureCenter.X + halfFigureSize/3, Y: pw.figureCenter.Y + halfFigureSize},
+	for _, br := range imageutil.Border(b.Bounds(), 10) {
+		draw.Draw(b.RGBA(), br, image.White, image.Point{}, draw.Src)
 	}
 
-	pw.w.Fill(horizontalRect, figureColor, draw.Src)
-	pw.w.Fill(verticalRect, figureColor, draw.Src)
+	figureSize := painter.DefaultFigureSize * float64(min(pw.sz.WidthPx, pw.sz.HeightPx))
+
+	shape, err := painter.ParseShape(painter.DefaultShape)
+	if err != nil {
+		log.Printf("ERROR: %s", err)
+		return
+	}
+	center := painter.Vec{X: float64(pw.figureCenter.X), Y: float64(pw.figureCenter.Y)}
+	painter.DrawShape(b.RGBA(), shape, center, figureSize, painter.DefaultFigureColor)
+	pw.w.Upload(image.Point{}, b, b.Bounds())
 }
\ No newline at end of file